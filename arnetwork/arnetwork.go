@@ -0,0 +1,352 @@
+/*
+Package arnetwork sends and receives the ARNetworkAL frames of the
+Parrot ARSDK protocol over a packet connection, and implements the
+acknowledged buffers on top of them.
+
+Every buffer have its own sequence number, which is increased for each
+frame sent on the buffer. A frame sent on an acknowledged buffer is
+sent again until an acknowledgement with its sequence number is
+received, or the number of retries is used up. What happens then is
+decided by the timeout policy of the command sent:
+
+	POP    the frame is dropped, and the next frame on the buffer is sent
+	RETRY  the frame is sent again until it is acknowledged
+	FLUSH  the frame is dropped together with all the frames waiting to
+	       be sent on the same buffer
+
+Only one frame is in flight on an acknowledged buffer at a time, so the
+frames are received in the same order as they are sent.
+*/
+package arnetwork
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/postmannen/lexmlparser/arnetworkal"
+	"github.com/postmannen/lexmlparser/arsdk"
+)
+
+// frameQueueSize is the number of frames received which can wait to be
+// read from the Frames channel before the manager stops reading from the
+// connection.
+const frameQueueSize = 64
+
+// The defaults used for the zero values of a Config.
+const (
+	DefaultAckTimeout = 150 * time.Millisecond
+	DefaultMaxRetries = 5
+)
+
+// The errors returned when sending a frame.
+var (
+	ErrTimeout = errors.New("frame was not acknowledged in time")
+	ErrFlushed = errors.New("frame was flushed from the buffer")
+	ErrClosed  = errors.New("manager is closed")
+)
+
+// Config is the settings for the acknowledged buffers of a Manager.
+type Config struct {
+	// AckTimeout is how long to wait for an acknowledgement before the
+	// frame is sent again.
+	AckTimeout time.Duration
+	// MaxRetries is the number of times a frame is sent again before
+	// the timeout policy is used. A negative value will never send a
+	// frame again.
+	MaxRetries int
+}
+
+// Manager sends frames to a remote address, and receives the frames
+// sent to its connection.
+type Manager struct {
+	conn   net.PacketConn
+	remote net.Addr
+	cfg    Config
+
+	// mu protects the buffers and the last sequence numbers received.
+	mu      sync.Mutex
+	buffers map[uint8]*buffer
+	// lastSeq is the sequence number of the last acknowledged frame
+	// received on a buffer, used to drop the frames received again
+	// when the acknowledgement was lost.
+	lastSeq map[uint8]uint8
+
+	frames    chan arnetworkal.Frame
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// buffer is the sending state of a single buffer.
+type buffer struct {
+	seq uint8
+	// sem is held by the sender of the frame in flight.
+	sem chan struct{}
+	// acks are the sequence numbers of the acknowledgements received.
+	acks chan uint8
+	// flushes is increased every time the buffer is flushed, so the
+	// senders waiting for the buffer knows their frame was flushed.
+	flushes int
+}
+
+// NewManager will return a new *Manager sending frames on conn to
+// remote, and start receiving the frames sent to conn.
+// The zero values of cfg are replaced with the defaults.
+func NewManager(conn net.PacketConn, remote net.Addr, cfg Config) *Manager {
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = DefaultAckTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+
+	m := &Manager{
+		conn:    conn,
+		remote:  remote,
+		cfg:     cfg,
+		buffers: map[uint8]*buffer{},
+		lastSeq: map[uint8]uint8{},
+		frames:  make(chan arnetworkal.Frame, frameQueueSize),
+		done:    make(chan struct{}),
+	}
+
+	go m.readLoop()
+
+	return m
+}
+
+// Frames will return the channel the data frames received are
+// delivered on. The acknowledgements are handled by the manager and
+// are not delivered. The channel is closed when the manager is closed
+// or the connection fails.
+func (m *Manager) Frames() <-chan arnetworkal.Frame {
+	return m.frames
+}
+
+// Close will stop the manager and close the connection.
+func (m *Manager) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		err = m.conn.Close()
+	})
+	return err
+}
+
+// Send will send the payload on a buffer without acknowledgement.
+func (m *Manager) Send(bufferID uint8, payload []byte) error {
+	buf := m.buffer(bufferID)
+
+	m.mu.Lock()
+	seq := buf.seq
+	buf.seq++
+	m.mu.Unlock()
+
+	f := arnetworkal.Frame{DataType: arnetworkal.DataTypeData, BufferID: bufferID, Seq: seq, Payload: payload}
+	return m.write(f)
+}
+
+// SendWithAck will send the payload on a buffer, and wait until it is
+// acknowledged. The timeout policy decides what to do if the frame
+// was not acknowledged after all the retries.
+// With TimeoutRetry the frame is sent until it is acknowledged, or ctx
+// is done.
+func (m *Manager) SendWithAck(ctx context.Context, bufferID uint8, payload []byte, policy arsdk.Timeout) error {
+	buf := m.buffer(bufferID)
+
+	m.mu.Lock()
+	flushes := buf.flushes
+	m.mu.Unlock()
+
+	// Wait for the frame in flight on the buffer to be done.
+	select {
+	case buf.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.done:
+		return ErrClosed
+	}
+	defer func() { <-buf.sem }()
+
+	m.mu.Lock()
+	if buf.flushes != flushes {
+		m.mu.Unlock()
+		return ErrFlushed
+	}
+	seq := buf.seq
+	buf.seq++
+	m.mu.Unlock()
+
+	// Drop the acknowledgements received late for the earlier frames.
+	for len(buf.acks) > 0 {
+		<-buf.acks
+	}
+
+	f := arnetworkal.Frame{DataType: arnetworkal.DataTypeDataWithAck, BufferID: bufferID, Seq: seq, Payload: payload}
+
+	timer := time.NewTimer(m.cfg.AckTimeout)
+	defer timer.Stop()
+
+	for retries := 0; ; retries++ {
+		if err := m.write(f); err != nil {
+			return err
+		}
+
+		acked, err := m.waitAck(ctx, buf, seq, timer)
+		if err != nil || acked {
+			return err
+		}
+
+		if retries < m.cfg.MaxRetries {
+			continue
+		}
+
+		switch policy {
+		case arsdk.TimeoutRetry:
+			retries = -1
+		case arsdk.TimeoutFlush:
+			m.mu.Lock()
+			buf.flushes++
+			m.mu.Unlock()
+			return ErrTimeout
+		default:
+			return ErrTimeout
+		}
+	}
+}
+
+// SendCommand will send the payload of a command on the buffer for the
+// buffer class b, using the timeout policy t if the buffer is
+// acknowledged. Both are normally found with arsdk.BufferOf and
+// arsdk.TimeoutOf for the command sent.
+func (m *Manager) SendCommand(ctx context.Context, b arsdk.Buffer, t arsdk.Timeout, payload []byte) error {
+	id := arnetworkal.BufferID(b)
+	if b == arsdk.BufferNonAck {
+		return m.Send(id, payload)
+	}
+	return m.SendWithAck(ctx, id, payload, t)
+}
+
+// waitAck will wait for the acknowledgement of seq on buf, and return
+// false if the timer fires first. The timer is reset for the next wait.
+func (m *Manager) waitAck(ctx context.Context, buf *buffer, seq uint8, timer *time.Timer) (bool, error) {
+	defer timer.Reset(m.cfg.AckTimeout)
+
+	for {
+		select {
+		case s := <-buf.acks:
+			if s != seq {
+				continue
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			return true, nil
+		case <-timer.C:
+			return false, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-m.done:
+			return false, ErrClosed
+		}
+	}
+}
+
+// buffer will return the state of the buffer with the given id, and
+// create it if it does not exist.
+func (m *Manager) buffer(id uint8) *buffer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buf, ok := m.buffers[id]
+	if !ok {
+		buf = &buffer{
+			sem:  make(chan struct{}, 1),
+			acks: make(chan uint8, 8),
+		}
+		m.buffers[id] = buf
+	}
+
+	return buf
+}
+
+func (m *Manager) write(f arnetworkal.Frame) error {
+	_, err := m.conn.WriteTo(f.Encode(nil), m.remote)
+	select {
+	case <-m.done:
+		return ErrClosed
+	default:
+		return err
+	}
+}
+
+// readLoop will read the datagrams sent to the connection until it is
+// closed, and handle all the frames found in them.
+func (m *Manager) readLoop() {
+	defer close(m.frames)
+
+	b := make([]byte, 65535)
+	for {
+		n, _, err := m.conn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+
+		// The frames decoded before a malformed one are still handled.
+		frames, _ := arnetworkal.DecodeDatagram(b[:n])
+		for _, f := range frames {
+			// The payload refers to b, which is used again for the
+			// next datagram.
+			f.Payload = append([]byte(nil), f.Payload...)
+			if !m.handle(f) {
+				return
+			}
+		}
+	}
+}
+
+// handle will handle a single frame received, and return false if the
+// manager was closed.
+func (m *Manager) handle(f arnetworkal.Frame) bool {
+	switch f.DataType {
+	case arnetworkal.DataTypeAck:
+		if len(f.Payload) < 1 {
+			return true
+		}
+		// The acknowledgements are sent on the buffer id + 128, and
+		// adding 128 again wraps around to the buffer acknowledged.
+		buf := m.buffer(arnetworkal.AckBufferID(f.BufferID))
+		select {
+		case buf.acks <- f.Payload[0]:
+		default:
+		}
+		return true
+
+	case arnetworkal.DataTypeDataWithAck:
+		ackID := arnetworkal.AckBufferID(f.BufferID)
+		ackBuf := m.buffer(ackID)
+
+		m.mu.Lock()
+		seq := ackBuf.seq
+		ackBuf.seq++
+		last, seen := m.lastSeq[f.BufferID]
+		m.lastSeq[f.BufferID] = f.Seq
+		m.mu.Unlock()
+
+		m.write(arnetworkal.Frame{DataType: arnetworkal.DataTypeAck, BufferID: ackID, Seq: seq, Payload: []byte{f.Seq}})
+
+		// The frame was sent again since our acknowledgement was lost.
+		if seen && last == f.Seq {
+			return true
+		}
+	}
+
+	select {
+	case m.frames <- f:
+		return true
+	case <-m.done:
+		return false
+	}
+}
@@ -0,0 +1,250 @@
+package arnetwork
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/postmannen/lexmlparser/arnetworkal"
+	"github.com/postmannen/lexmlparser/arsdk"
+)
+
+var testConfig = Config{AckTimeout: 20 * time.Millisecond, MaxRetries: 2}
+
+// listen will return a new udp connection on the loopback interface.
+func listen(t *testing.T) net.PacketConn {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return conn
+}
+
+// newPair will return two managers sending to each other.
+func newPair(t *testing.T) (*Manager, *Manager) {
+	t.Helper()
+
+	ca, cb := listen(t), listen(t)
+	a := NewManager(ca, cb.LocalAddr(), testConfig)
+	b := NewManager(cb, ca.LocalAddr(), testConfig)
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+// peer is the other end of a manager, which reads the frames sent by
+// the manager and decides itself when to acknowledge them.
+type peer struct {
+	t    *testing.T
+	conn net.PacketConn
+	m    *Manager
+}
+
+func newPeer(t *testing.T) *peer {
+	t.Helper()
+
+	conn := listen(t)
+	mconn := listen(t)
+	p := &peer{t: t, conn: conn, m: NewManager(mconn, conn.LocalAddr(), testConfig)}
+	t.Cleanup(func() {
+		p.m.Close()
+		conn.Close()
+	})
+	return p
+}
+
+// read will read the next frame sent by the manager.
+func (p *peer) read() arnetworkal.Frame {
+	p.t.Helper()
+
+	b := make([]byte, 1024)
+	p.conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := p.conn.ReadFrom(b)
+	if err != nil {
+		p.t.Fatalf("read: %v", err)
+	}
+	f, _, err := arnetworkal.DecodeFrame(b[:n])
+	if err != nil {
+		p.t.Fatalf("decode: %v", err)
+	}
+	return f
+}
+
+// write will send the frame to the manager.
+func (p *peer) write(f arnetworkal.Frame) {
+	p.t.Helper()
+
+	_, err := p.conn.WriteTo(f.Encode(nil), p.m.conn.LocalAddr())
+	if err != nil {
+		p.t.Fatalf("write: %v", err)
+	}
+}
+
+// ack will acknowledge the frame.
+func (p *peer) ack(f arnetworkal.Frame) {
+	p.write(arnetworkal.Frame{DataType: arnetworkal.DataTypeAck, BufferID: arnetworkal.AckBufferID(f.BufferID), Payload: []byte{f.Seq}})
+}
+
+// send will start sending the payload with acknowledgement, and return
+// the channel the result is delivered on.
+func (p *peer) send(payload []byte, policy arsdk.Timeout) chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.m.SendWithAck(context.Background(), arnetworkal.BufferIDAck, payload, policy)
+	}()
+	return errCh
+}
+
+func TestSendWithAck(t *testing.T) {
+	a, b := newPair(t)
+
+	for i := 0; i < 3; i++ {
+		err := a.SendWithAck(context.Background(), arnetworkal.BufferIDAck, []byte{byte(i)}, arsdk.TimeoutPop)
+		if err != nil {
+			t.Fatalf("SendWithAck: %v", err)
+		}
+
+		f := <-b.Frames()
+		if f.DataType != arnetworkal.DataTypeDataWithAck || f.Seq != uint8(i) || !bytes.Equal(f.Payload, []byte{byte(i)}) {
+			t.Errorf("got frame %+v", f)
+		}
+	}
+
+	err := a.Send(arnetworkal.BufferIDNonAck, []byte{9})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if f := <-b.Frames(); f.DataType != arnetworkal.DataTypeData || f.BufferID != arnetworkal.BufferIDNonAck {
+		t.Errorf("got frame %+v", f)
+	}
+}
+
+func TestSendWithAckRetransmit(t *testing.T) {
+	p := newPeer(t)
+	errCh := p.send([]byte{1}, arsdk.TimeoutPop)
+
+	// The frame is sent again with the same sequence number until it is
+	// acknowledged.
+	first := p.read()
+	again := p.read()
+	if again.Seq != first.Seq || !bytes.Equal(again.Payload, first.Payload) {
+		t.Errorf("got frame %+v sent again, want %+v", again, first)
+	}
+	p.ack(again)
+
+	if err := <-errCh; err != nil {
+		t.Errorf("SendWithAck: %v", err)
+	}
+}
+
+func TestSendWithAckPop(t *testing.T) {
+	p := newPeer(t)
+	errCh := p.send([]byte{1}, arsdk.TimeoutPop)
+
+	// Sent once, and then again for each of the retries.
+	for i := 0; i < testConfig.MaxRetries+1; i++ {
+		p.read()
+	}
+	if err := <-errCh; !errors.Is(err, ErrTimeout) {
+		t.Errorf("got error %v, want %v", err, ErrTimeout)
+	}
+
+	// The next frame is sent with the next sequence number.
+	errCh = p.send([]byte{2}, arsdk.TimeoutPop)
+	f := p.read()
+	if f.Seq != 1 {
+		t.Errorf("got sequence number %v, want 1", f.Seq)
+	}
+	p.ack(f)
+	if err := <-errCh; err != nil {
+		t.Errorf("SendWithAck: %v", err)
+	}
+}
+
+func TestSendWithAckRetry(t *testing.T) {
+	p := newPeer(t)
+	errCh := p.send([]byte{1}, arsdk.TimeoutRetry)
+
+	// Keeps sending after all the retries are used.
+	var f arnetworkal.Frame
+	for i := 0; i < 2*(testConfig.MaxRetries+1); i++ {
+		f = p.read()
+	}
+	p.ack(f)
+
+	if err := <-errCh; err != nil {
+		t.Errorf("SendWithAck: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := p.m.SendWithAck(ctx, arnetworkal.BufferIDAck, []byte{2}, arsdk.TimeoutRetry)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSendWithAckFlush(t *testing.T) {
+	p := newPeer(t)
+	errCh := p.send([]byte{1}, arsdk.TimeoutFlush)
+	p.read()
+
+	// Wait for the second frame on the buffer while the first one is in
+	// flight.
+	waitCh := p.send([]byte{2}, arsdk.TimeoutPop)
+	time.Sleep(5 * time.Millisecond)
+
+	if err := <-errCh; !errors.Is(err, ErrTimeout) {
+		t.Errorf("got error %v, want %v", err, ErrTimeout)
+	}
+	if err := <-waitCh; !errors.Is(err, ErrFlushed) {
+		t.Errorf("got error %v, want %v", err, ErrFlushed)
+	}
+}
+
+func TestReceiveDuplicate(t *testing.T) {
+	p := newPeer(t)
+
+	// The frame is sent again since the acknowledgement was lost, and
+	// should only be delivered once, but acknowledged both times.
+	f := arnetworkal.Frame{DataType: arnetworkal.DataTypeDataWithAck, BufferID: arnetworkal.BufferIDEventAck, Seq: 7, Payload: []byte{1}}
+	p.write(f)
+	p.write(f)
+	f.Seq = 8
+	p.write(f)
+
+	for i := 0; i < 3; i++ {
+		ack := p.read()
+		if ack.DataType != arnetworkal.DataTypeAck || ack.BufferID != arnetworkal.AckBufferID(arnetworkal.BufferIDEventAck) {
+			t.Errorf("got ack %+v", ack)
+		}
+	}
+
+	if got := <-p.m.Frames(); got.Seq != 7 {
+		t.Errorf("got frame %+v, want sequence number 7", got)
+	}
+	if got := <-p.m.Frames(); got.Seq != 8 {
+		t.Errorf("got frame %+v, want sequence number 8", got)
+	}
+}
+
+func TestClose(t *testing.T) {
+	p := newPeer(t)
+	errCh := p.send([]byte{1}, arsdk.TimeoutRetry)
+	p.read()
+
+	p.m.Close()
+	if err := <-errCh; !errors.Is(err, ErrClosed) {
+		t.Errorf("got error %v, want %v", err, ErrClosed)
+	}
+	if _, ok := <-p.m.Frames(); ok {
+		t.Errorf("the frames channel should be closed")
+	}
+}
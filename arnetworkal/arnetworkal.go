@@ -0,0 +1,219 @@
+/*
+Package arnetworkal builds and parses the ARNetworkAL frames which the
+commands of the Parrot ARSDK protocol are sent within.
+
+A frame have a 7 byte header followed by the payload:
+
+	data type   u8
+	buffer id   u8
+	sequence    u8
+	size        u32 little endian, including the header
+
+Several frames can be sent after each other in a single UDP datagram.
+
+The payload of a frame carrying a command starts with a 4 byte command
+header followed by the arguments of the command:
+
+	project     u8
+	class       u8
+	command     u16 little endian
+
+The generated code declares a CommandMap with a decoder for every
+command, and Dispatch uses it to decode the arguments of a command.
+*/
+package arnetworkal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/postmannen/lexmlparser/arsdk"
+)
+
+// DataType is the type of the data carried by a frame.
+type DataType uint8
+
+// The data types of a frame.
+const (
+	DataTypeAck         DataType = 1
+	DataTypeData        DataType = 2
+	DataTypeLowLatency  DataType = 3
+	DataTypeDataWithAck DataType = 4
+)
+
+func (d DataType) String() string {
+	switch d {
+	case DataTypeAck:
+		return "ack"
+	case DataTypeData:
+		return "data"
+	case DataTypeLowLatency:
+		return "low latency"
+	case DataTypeDataWithAck:
+		return "data with ack"
+	}
+	return fmt.Sprintf("DataType(%d)", uint8(d))
+}
+
+// The ids of the buffers used by the controller to send commands to the
+// drone, and by the drone to send events to the controller.
+const (
+	BufferIDNonAck      uint8 = 10
+	BufferIDAck         uint8 = 11
+	BufferIDHighPrio    uint8 = 12
+	BufferIDEventAck    uint8 = 126
+	BufferIDEventNonAck uint8 = 127
+)
+
+// BufferID will return the id of the buffer to send a command on for
+// the buffer class of the command.
+func BufferID(b arsdk.Buffer) uint8 {
+	switch b {
+	case arsdk.BufferNonAck:
+		return BufferIDNonAck
+	case arsdk.BufferHighPrio:
+		return BufferIDHighPrio
+	}
+	return BufferIDAck
+}
+
+// AckBufferID will return the id of the buffer used to acknowledge the
+// frames received on bufferID.
+func AckBufferID(bufferID uint8) uint8 {
+	return bufferID + 128
+}
+
+// HeaderSize is the size of the frame header.
+const HeaderSize = 7
+
+// CommandHeaderSize is the size of the project, class and command ids
+// in the start of the payload of a command.
+const CommandHeaderSize = 4
+
+// The errors returned when a frame or a command could not be decoded.
+var (
+	ErrShortFrame     = errors.New("frame too short")
+	ErrFrameSize      = errors.New("invalid frame size")
+	ErrUnknownCommand = errors.New("unknown command")
+)
+
+// Frame is a single ARNetworkAL frame.
+type Frame struct {
+	DataType DataType
+	BufferID uint8
+	Seq      uint8
+	Payload  []byte
+}
+
+// Encode will append the frame with its header to b, and return the
+// extended slice.
+func (f Frame) Encode(b []byte) []byte {
+	b = append(b, byte(f.DataType), f.BufferID, f.Seq)
+	b = appendUint32(b, uint32(HeaderSize+len(f.Payload)))
+	return append(b, f.Payload...)
+}
+
+// DecodeFrame will decode the first frame found in b, and return it
+// together with the number of bytes of b it used.
+// The payload of the frame refers to the same memory as b.
+func DecodeFrame(b []byte) (Frame, int, error) {
+	if len(b) < HeaderSize {
+		return Frame{}, 0, fmt.Errorf("decoding frame header: %w", ErrShortFrame)
+	}
+
+	size := binary.LittleEndian.Uint32(b[3:7])
+	if size < HeaderSize {
+		return Frame{}, 0, fmt.Errorf("decoding frame with size %v: %w", size, ErrFrameSize)
+	}
+	if uint64(size) > uint64(len(b)) {
+		return Frame{}, 0, fmt.Errorf("decoding frame with size %v, got %v bytes: %w", size, len(b), ErrShortFrame)
+	}
+
+	f := Frame{
+		DataType: DataType(b[0]),
+		BufferID: b[1],
+		Seq:      b[2],
+		Payload:  b[HeaderSize:size],
+	}
+
+	return f, int(size), nil
+}
+
+// DecodeDatagram will decode all the frames found in a single UDP
+// datagram. The frames decoded before an error is found are returned
+// together with the error.
+func DecodeDatagram(b []byte) ([]Frame, error) {
+	var frames []Frame
+
+	for offset := 0; offset < len(b); {
+		f, n, err := DecodeFrame(b[offset:])
+		if err != nil {
+			return frames, fmt.Errorf("frame %v at offset %v: %w", len(frames), offset, err)
+		}
+		frames = append(frames, f)
+		offset += n
+	}
+
+	return frames, nil
+}
+
+// EncodeCommand will append the command header and the encoded
+// arguments to b, and return the extended slice. The result is the
+// payload of a frame.
+func EncodeCommand(b []byte, cmd arsdk.Command, args arsdk.Encoder) []byte {
+	b = append(b, byte(cmd.Project), byte(cmd.Class), byte(cmd.Cmd), byte(cmd.Cmd>>8))
+	if args != nil {
+		b = args.Encode(b)
+	}
+	return b
+}
+
+// DecodeCommand will decode the command header in the start of the
+// payload of a frame, and return the command and the rest of the
+// payload holding the arguments.
+func DecodeCommand(payload []byte) (arsdk.Command, []byte, error) {
+	if len(payload) < CommandHeaderSize {
+		return arsdk.Command{}, nil, fmt.Errorf("decoding command header: %w", ErrShortFrame)
+	}
+
+	cmd := arsdk.Command{
+		Project: arsdk.ProjectDef(payload[0]),
+		Class:   arsdk.ClassDef(payload[1]),
+		Cmd:     arsdk.CmdDef(binary.LittleEndian.Uint16(payload[2:4])),
+	}
+
+	return cmd, payload[CommandHeaderSize:], nil
+}
+
+// Dispatch will decode the command in the payload of a frame with the
+// decoder found for the command in commands, which is normally the
+// CommandMap of the generated code. The command decoded is given to the
+// handlers, like an arsdk.State kept up to date with the events received.
+func Dispatch(commands map[arsdk.Command]arsdk.Decoder, payload []byte, handlers ...arsdk.Handler) (arsdk.Command, arsdk.Arguments, error) {
+	cmd, args, err := DecodeCommand(payload)
+	if err != nil {
+		return cmd, nil, err
+	}
+
+	d, ok := commands[cmd]
+	if !ok {
+		return cmd, nil, fmt.Errorf("command %+v: %w", cmd, ErrUnknownCommand)
+	}
+
+	arg, err := d.Decode(args)
+	if err != nil {
+		return cmd, nil, err
+	}
+
+	for _, h := range handlers {
+		h.Handle(cmd, arg)
+	}
+
+	return cmd, arg, nil
+}
+
+// appendUint32 will append v as little endian to b.
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
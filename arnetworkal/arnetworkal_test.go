@@ -0,0 +1,157 @@
+package arnetworkal
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/postmannen/lexmlparser/arsdk"
+)
+
+// testArguments is the arguments of a test command with a single u8.
+type testArguments struct {
+	V uint8
+}
+
+func (a testArguments) Encode(b []byte) []byte {
+	return append(b, a.V)
+}
+
+type testCmd arsdk.Command
+
+func (c testCmd) Decode(b []byte) (arsdk.Arguments, error) {
+	if len(b) < 1 {
+		return nil, &arsdk.DecodeError{Cmd: "testCmd", Arg: "V", Err: arsdk.ErrShortPayload}
+	}
+	return testArguments{V: b[0]}, nil
+}
+
+var testPCMD = arsdk.Command{Project: 1, Class: 0, Cmd: 2}
+
+var testCommandMap = map[arsdk.Command]arsdk.Decoder{
+	testPCMD: testCmd(testPCMD),
+}
+
+func TestFrameEncode(t *testing.T) {
+	f := Frame{DataType: DataTypeData, BufferID: 10, Seq: 3, Payload: []byte{1, 0, 2, 0, 7}}
+
+	want := []byte{2, 10, 3, 12, 0, 0, 0, 1, 0, 2, 0, 7}
+	if got := f.Encode(nil); !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeDatagram(t *testing.T) {
+	var b []byte
+	frames := []Frame{
+		{DataType: DataTypeData, BufferID: 10, Seq: 3, Payload: EncodeCommand(nil, testPCMD, testArguments{V: 7})},
+		{DataType: DataTypeAck, BufferID: 139, Seq: 1, Payload: []byte{5}},
+		{DataType: DataTypeLowLatency, BufferID: 125, Seq: 0, Payload: []byte{}},
+	}
+	for _, f := range frames {
+		b = f.Encode(b)
+	}
+
+	got, err := DecodeDatagram(b)
+	if err != nil {
+		t.Fatalf("DecodeDatagram: %v", err)
+	}
+	if len(got) != len(frames) {
+		t.Fatalf("got %v frames, want %v", len(got), len(frames))
+	}
+	for i := range frames {
+		if got[i].DataType != frames[i].DataType || got[i].BufferID != frames[i].BufferID ||
+			got[i].Seq != frames[i].Seq || !bytes.Equal(got[i].Payload, frames[i].Payload) {
+			t.Errorf("frame %v: got %+v, want %+v", i, got[i], frames[i])
+		}
+	}
+}
+
+func TestDecodeFrameErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		err  error
+	}{
+		{"short header", []byte{2, 10, 3, 12}, ErrShortFrame},
+		{"size below header", []byte{2, 10, 3, 6, 0, 0, 0}, ErrFrameSize},
+		{"size above datagram", []byte{2, 10, 3, 9, 0, 0, 0, 1}, ErrShortFrame},
+		{"huge size", []byte{2, 10, 3, 0xff, 0xff, 0xff, 0xff}, ErrShortFrame},
+	}
+
+	for _, tt := range tests {
+		_, _, err := DecodeFrame(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v: got error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// The frames before the bad one are still returned.
+	b := Frame{DataType: DataTypeData, Payload: []byte{1}}.Encode(nil)
+	frames, err := DecodeDatagram(append(b, 2, 10))
+	if len(frames) != 1 || !errors.Is(err, ErrShortFrame) {
+		t.Errorf("got %v frames and error %v", len(frames), err)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	payload := EncodeCommand(nil, testPCMD, testArguments{V: 7})
+
+	cmd, arg, err := Dispatch(testCommandMap, payload)
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if cmd != testPCMD || arg != (testArguments{V: 7}) {
+		t.Errorf("got %+v %+v", cmd, arg)
+	}
+
+	_, _, err = Dispatch(testCommandMap, EncodeCommand(nil, arsdk.Command{Project: 1, Cmd: 3}, nil))
+	if !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("got error %v, want %v", err, ErrUnknownCommand)
+	}
+
+	_, _, err = Dispatch(testCommandMap, payload[:4])
+	if !errors.Is(err, arsdk.ErrShortPayload) {
+		t.Errorf("got error %v, want %v", err, arsdk.ErrShortPayload)
+	}
+
+	_, _, err = Dispatch(testCommandMap, payload[:3])
+	if !errors.Is(err, ErrShortFrame) {
+		t.Errorf("got error %v, want %v", err, ErrShortFrame)
+	}
+}
+
+func TestDispatchHandlers(t *testing.T) {
+	state := arsdk.NewState(map[arsdk.Command]bool{testPCMD: true})
+
+	_, _, err := Dispatch(testCommandMap, EncodeCommand(nil, testPCMD, testArguments{V: 7}), state)
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if got := state.Get(testPCMD); got != (testArguments{V: 7}) {
+		t.Errorf("got state %+v", got)
+	}
+
+	// The handlers are not given the commands which could not be decoded.
+	Dispatch(testCommandMap, EncodeCommand(nil, testPCMD, nil), state)
+	if got := state.Get(testPCMD); got != (testArguments{V: 7}) {
+		t.Errorf("got state %+v after a short payload", got)
+	}
+}
+
+func TestBufferID(t *testing.T) {
+	tests := []struct {
+		buffer arsdk.Buffer
+		want   uint8
+	}{
+		{arsdk.BufferNonAck, 10},
+		{arsdk.BufferAck, 11},
+		{arsdk.BufferHighPrio, 12},
+	}
+
+	for _, tt := range tests {
+		if got := BufferID(tt.buffer); got != tt.want {
+			t.Errorf("BufferID(%v): got %v, want %v", tt.buffer, got, tt.want)
+		}
+	}
+}
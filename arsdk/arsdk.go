@@ -0,0 +1,95 @@
+/*
+Package arsdk holds the runtime helpers used by the code generated by
+lexmlparser for the Parrot ARSDK protocol.
+
+The generated code imports this package to decode the little endian
+arguments of the commands, instead of having the helpers printed into
+every generated file.
+*/
+package arsdk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The errors wrapped in a *DecodeError when the payload of a command
+// could not be decoded.
+var (
+	ErrShortPayload       = errors.New("payload too short")
+	ErrNoStringTerminator = errors.New("no null terminator found for string")
+	ErrTrailingData       = errors.New("trailing data after the arguments")
+)
+
+// DecodeError is returned by the decode methods with the command, the
+// argument, and the offset into the payload where decoding failed.
+type DecodeError struct {
+	Cmd    string
+	Arg    string
+	Offset int
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Arg == "" {
+		return fmt.Sprintf("decoding %v: at offset %v: %v", e.Cmd, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("decoding %v: argument %v at offset %v: %v", e.Cmd, e.Arg, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// LenStringData takes a []byte which is the data for the arguments, and
+// returns the length of the string including the 0 terminator.
+// The slice should be sliced to start from the offset of the string.
+func LenStringData(b []byte) (int, error) {
+	for i := 0; i < len(b); i++ {
+		if b[i] == 0 {
+			// add 1 to jump to the 0
+			return i + 1, nil
+		}
+	}
+
+	return 0, ErrNoStringTerminator
+}
+
+// ConvLittleEndianSliceToNumeric takes a []byte, and an *out variable of type
+// uint8/int8/uint16/int16/uint32/int32/uint64/int64/float32/float64/string
+// and convert the []byte, and places the result into the *out variable.
+// The []byte must hold at least as many bytes as the size of the type.
+func ConvLittleEndianSliceToNumeric(in []byte, out interface{}) error {
+	switch out := out.(type) {
+	case *uint8:
+		*out = uint8(in[0])
+	case *int8:
+		*out = int8(in[0])
+	case *uint16:
+		*out = binary.LittleEndian.Uint16(in)
+	case *int16:
+		*out = int16(binary.LittleEndian.Uint16(in))
+	case *uint32:
+		*out = binary.LittleEndian.Uint32(in)
+	case *int32:
+		*out = int32(binary.LittleEndian.Uint32(in))
+	case *uint64:
+		*out = binary.LittleEndian.Uint64(in)
+	case *int64:
+		*out = int64(binary.LittleEndian.Uint64(in))
+	case *float32:
+		bits := binary.LittleEndian.Uint32(in)
+		*out = math.Float32frombits(bits)
+	case *float64:
+		bits := binary.LittleEndian.Uint64(in)
+		*out = math.Float64frombits(bits)
+	case *string:
+		*out = string(in)
+	default:
+		return fmt.Errorf("ConvLittleEndianSliceToNumeric: unsupported type %T", out)
+	}
+
+	return nil
+}
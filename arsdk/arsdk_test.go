@@ -0,0 +1,128 @@
+package arsdk
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestConvLittleEndianSliceToNumeric(t *testing.T) {
+	tests := []struct {
+		xmlType string
+		in      []byte
+		out     interface{}
+		want    interface{}
+	}{
+		{"u8", []byte{0xfe}, new(uint8), uint8(0xfe)},
+		{"i8", []byte{0xfe}, new(int8), int8(-2)},
+		{"u16", []byte{0x34, 0x12}, new(uint16), uint16(0x1234)},
+		{"i16", []byte{0xfe, 0xff}, new(int16), int16(-2)},
+		{"u32", []byte{0x78, 0x56, 0x34, 0x12}, new(uint32), uint32(0x12345678)},
+		{"i32", []byte{0xfe, 0xff, 0xff, 0xff}, new(int32), int32(-2)},
+		{"u64", []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01}, new(uint64), uint64(0x0102030405060708)},
+		{"i64", []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01}, new(int64), int64(0x0102030405060708)},
+		{"i64 negative", []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, new(int64), int64(-2)},
+		{"float", []byte{0x00, 0x00, 0xc0, 0x3f}, new(float32), float32(1.5)},
+		{"double", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0xbf}, new(float64), float64(-1.5)},
+		{"string", []byte("bebop"), new(string), "bebop"},
+	}
+
+	for _, tt := range tests {
+		err := ConvLittleEndianSliceToNumeric(tt.in, tt.out)
+		if err != nil {
+			t.Errorf("%v: %v", tt.xmlType, err)
+			continue
+		}
+		got := reflect.ValueOf(tt.out).Elem().Interface()
+		if got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.xmlType, got, tt.want)
+		}
+	}
+
+	err := ConvLittleEndianSliceToNumeric([]byte{1}, new(bool))
+	if err == nil {
+		t.Errorf("got no error for an unsupported type")
+	}
+}
+
+func TestConvLittleEndianSliceToNumericNaN(t *testing.T) {
+	var f float64
+	in := []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x7f}
+	if err := ConvLittleEndianSliceToNumeric(in, &f); err != nil || !math.IsNaN(f) {
+		t.Errorf("got %v and error %v, want NaN", f, err)
+	}
+}
+
+func TestLenStringData(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want int
+		err  error
+	}{
+		{[]byte{0}, 1, nil},
+		{[]byte{'a', 'b', 0, 'c'}, 3, nil},
+		{[]byte{'a', 'b'}, 0, ErrNoStringTerminator},
+		{nil, 0, ErrNoStringTerminator},
+	}
+
+	for _, tt := range tests {
+		got, err := LenStringData(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("LenStringData(%q): got %v, %v, want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	err := error(&DecodeError{Cmd: "CommonSettingsProductName", Arg: "Name", Offset: 2, Err: ErrNoStringTerminator})
+
+	want := "decoding CommonSettingsProductName: argument Name at offset 2: no null terminator found for string"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+	if !errors.Is(err, ErrNoStringTerminator) {
+		t.Errorf("the DecodeError should unwrap to ErrNoStringTerminator")
+	}
+}
+
+type testCmd struct{}
+
+func (testCmd) Decode(b []byte) (Arguments, error) { return nil, nil }
+
+type testBufferCmd struct{ testCmd }
+
+func (testBufferCmd) Buffer() Buffer { return BufferHighPrio }
+
+func (testBufferCmd) Timeout() Timeout { return TimeoutRetry }
+
+func TestBufferOf(t *testing.T) {
+	if b := BufferOf(testCmd{}); b != BufferAck {
+		t.Errorf("got %v, want the default ACK", b)
+	}
+	if b := BufferOf(testBufferCmd{}); b != BufferHighPrio {
+		t.Errorf("got %v, want HIGH_PRIO", b)
+	}
+}
+
+func TestTimeoutOf(t *testing.T) {
+	if v := TimeoutOf(testCmd{}); v != TimeoutPop {
+		t.Errorf("got %v, want the default POP", v)
+	}
+	if v := TimeoutOf(testBufferCmd{}); v != TimeoutRetry {
+		t.Errorf("got %v, want RETRY", v)
+	}
+}
+
+func (testBufferCmd) Expectations() []Expectation {
+	return []Expectation{{OneOf: []ExpectedMsg{{Command: Command{Project: 1, Class: 4, Cmd: 1}}}}}
+}
+
+func TestExpectationsOf(t *testing.T) {
+	if e := ExpectationsOf(testCmd{}); e != nil {
+		t.Errorf("got %+v, want no expectations", e)
+	}
+	if e := ExpectationsOf(testBufferCmd{}); len(e) != 1 || e[0].OneOf[0].Command.Class != 4 {
+		t.Errorf("got %+v", e)
+	}
+}
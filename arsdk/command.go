@@ -0,0 +1,176 @@
+package arsdk
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProjectDef is the id of a project or a feature.
+type ProjectDef uint8
+
+// ClassDef is the id of a class within a project. The messages of a
+// feature are all sent with a class id of 0.
+type ClassDef uint8
+
+// CmdDef is the id of a command within a class.
+type CmdDef uint16
+
+// Command identifies a command by its project, class and command id,
+// which are the first 4 bytes of the payload of a command.
+type Command struct {
+	Project ProjectDef
+	Class   ClassDef
+	Cmd     CmdDef
+}
+
+// Arguments is the decoded arguments of a command.
+type Arguments interface {
+	Encoder
+}
+
+// Decoder is implemented by the generated command types, and decodes
+// the arguments of the command.
+type Decoder interface {
+	Decode([]byte) (Arguments, error)
+}
+
+// Encoder is implemented by the generated argument types, and appends
+// the arguments encoded as little endian to a slice.
+type Encoder interface {
+	Encode([]byte) []byte
+}
+
+// DecodeStrict will decode b with d, and also return an error if b
+// holds more data than the arguments of the command.
+func DecodeStrict(d Decoder, b []byte) (Arguments, error) {
+	arg, err := d.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// The encoded arguments have the same length as the part of the
+	// payload that was decoded.
+	n := len(arg.Encode(nil))
+	if n != len(b) {
+		// Use the type name of the command without the package name.
+		cmd := fmt.Sprintf("%T", d)
+		cmd = cmd[strings.LastIndex(cmd, ".")+1:]
+		return nil, &DecodeError{Cmd: cmd, Offset: n, Err: ErrTrailingData}
+	}
+
+	return arg, nil
+}
+
+// Buffer is the class of network buffer a command should be sent on,
+// as given by the buffer attribute of the command in the xml.
+type Buffer uint8
+
+// The buffer classes of the commands. ACK is the default if the xml
+// does not give a buffer class for a command.
+const (
+	BufferNonAck Buffer = iota
+	BufferAck
+	BufferHighPrio
+)
+
+func (b Buffer) String() string {
+	switch b {
+	case BufferNonAck:
+		return "NON_ACK"
+	case BufferAck:
+		return "ACK"
+	case BufferHighPrio:
+		return "HIGH_PRIO"
+	}
+	return fmt.Sprintf("Buffer(%d)", uint8(b))
+}
+
+// BufferOf will return the buffer class of the command decoded by d,
+// which is normally a value from the CommandMap of the generated code.
+// BufferAck is returned if d does not have a Buffer method.
+func BufferOf(d Decoder) Buffer {
+	if b, ok := d.(interface{ Buffer() Buffer }); ok {
+		return b.Buffer()
+	}
+	return BufferAck
+}
+
+// Timeout is the policy to use when a command sent on an acknowledged
+// buffer was not acknowledged in time, as given by the timeout
+// attribute of the command in the xml.
+type Timeout uint8
+
+// The timeout policies of the commands. POP is the default if the xml
+// does not give a timeout policy for a command.
+const (
+	// TimeoutPop will drop the command.
+	TimeoutPop Timeout = iota
+	// TimeoutRetry will keep sending the command until it is acknowledged.
+	TimeoutRetry
+	// TimeoutFlush will drop the command, and all the commands waiting
+	// to be sent on the same buffer.
+	TimeoutFlush
+)
+
+func (t Timeout) String() string {
+	switch t {
+	case TimeoutPop:
+		return "POP"
+	case TimeoutRetry:
+		return "RETRY"
+	case TimeoutFlush:
+		return "FLUSH"
+	}
+	return fmt.Sprintf("Timeout(%d)", uint8(t))
+}
+
+// TimeoutOf will return the timeout policy of the command decoded by d,
+// which is normally a value from the CommandMap of the generated code.
+// TimeoutPop is returned if d does not have a Timeout method.
+func TimeoutOf(d Decoder) Timeout {
+	if t, ok := d.(interface{ Timeout() Timeout }); ok {
+		return t.Timeout()
+	}
+	return TimeoutPop
+}
+
+// Expectation is a message expected to be received after a command was
+// sent, as given in the expectations of the command in the xml.
+type Expectation struct {
+	// Delayed is true if the message can be received some time after
+	// the command was sent, and false if it is expected immediately.
+	Delayed bool
+	// OneOf are the messages which meet the expectation.
+	OneOf []ExpectedMsg
+}
+
+// ExpectedMsg is an expected message, with the values expected for its
+// arguments.
+type ExpectedMsg struct {
+	// Ref is the reference to the message as written in the xml, like
+	// #1-4-1.
+	Ref     string
+	Command Command
+	Args    []ArgPredicate
+}
+
+// ArgPredicate is the value expected for an argument of an expected
+// message. The argument is referred to by its name in the xml.
+type ArgPredicate struct {
+	Name string
+	// Value is the expected value, like the name of an enum value.
+	Value string
+	// This is the name of the argument of the command sent which the
+	// value is expected to be equal to. Value is empty if This is set.
+	This string
+}
+
+// ExpectationsOf will return the expectations of the command decoded
+// by d, which is normally a value from the CommandMap of the generated
+// code, or nil if the command have no expectations.
+func ExpectationsOf(d Decoder) []Expectation {
+	if e, ok := d.(interface{ Expectations() []Expectation }); ok {
+		return e.Expectations()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package arsdk
+
+import "sync"
+
+// Dispatcher calls the functions registered for a command every time
+// the command is received. The generated code wraps it in a Dispatcher
+// type with a typed On method for every command, like
+// OnArdrone3PilotingStateFlyingStateChanged, so the handlers are given
+// the arguments struct of the command instead of Arguments.
+//
+// A Dispatcher is safe for concurrent use.
+type Dispatcher struct {
+	subs subscribers
+}
+
+// NewDispatcher will return a new Dispatcher with no handlers.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// On will call fn with the arguments every time the command is
+// received, and return a function which removes the handler.
+func (d *Dispatcher) On(cmd Command, fn func(Arguments)) (unsubscribe func()) {
+	return d.subs.add(&subscriber{cmd: cmd, fn: withArguments(func(_ Command, args Arguments) { fn(args) })})
+}
+
+// OnAny will call fn with every command received, and return a function
+// which removes the handler.
+func (d *Dispatcher) OnAny(fn func(Command, Arguments)) (unsubscribe func()) {
+	return d.subs.add(&subscriber{all: true, fn: withArguments(fn)})
+}
+
+// Handle will call the functions registered for the command, in the
+// order they were registered, so d can be given to arnetworkal.Dispatch.
+func (d *Dispatcher) Handle(cmd Command, args Arguments) {
+	d.subs.call(cmd, args)
+}
+
+// subscribers are the functions subscribed to a single command, or to
+// all the commands. The value given to the functions is the Arguments
+// of the command for a State or a Dispatcher, and the List of the
+// command for a Collector.
+type subscribers struct {
+	mu   sync.Mutex
+	subs []*subscriber
+}
+
+type subscriber struct {
+	cmd Command
+	// all is true if the subscription is for all the commands.
+	all bool
+	fn  func(Command, interface{})
+}
+
+// add will add the subscriber, and return a function which removes it.
+func (s *subscribers) add(sub *subscriber) func() {
+	s.mu.Lock()
+	s.subs = append(s.subs, sub)
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// A new slice is created, since call might be calling the
+		// functions of the old one.
+		var subs []*subscriber
+		for _, v := range s.subs {
+			if v != sub {
+				subs = append(subs, v)
+			}
+		}
+		s.subs = subs
+	}
+}
+
+// call will call the functions subscribed to the command with v, in the
+// order they were subscribed. The functions are called without holding
+// the lock, so they can subscribe and unsubscribe.
+func (s *subscribers) call(cmd Command, v interface{}) {
+	s.mu.Lock()
+	subs := s.subs
+	s.mu.Unlock()
+
+	for _, sub := range subs {
+		if sub.all || sub.cmd == cmd {
+			sub.fn(cmd, v)
+		}
+	}
+}
+
+// withArguments will return fn as the function of a subscriber given
+// the Arguments of the commands.
+func withArguments(fn func(Command, Arguments)) func(Command, interface{}) {
+	return func(cmd Command, v interface{}) {
+		args, _ := v.(Arguments)
+		fn(cmd, args)
+	}
+}
@@ -0,0 +1,57 @@
+package arsdk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDispatcher(t *testing.T) {
+	takeOff := Command{Project: 1, Class: 0, Cmd: 1}
+	landing := Command{Project: 1, Class: 0, Cmd: 3}
+	d := NewDispatcher()
+
+	var got []string
+	off1 := d.On(takeOff, func(args Arguments) {
+		got = append(got, "first")
+	})
+	d.On(takeOff, func(args Arguments) {
+		got = append(got, "second")
+	})
+	d.OnAny(func(cmd Command, args Arguments) {
+		if cmd == landing {
+			got = append(got, "any landing")
+		}
+	})
+
+	d.Handle(takeOff, rawArgs{})
+	d.Handle(landing, rawArgs{})
+	want := []string{"first", "second", "any landing"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	off1()
+	got = nil
+	d.Handle(takeOff, rawArgs{})
+	if !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("got %v after removing the first handler", got)
+	}
+}
+
+func TestDispatcherUnsubscribeInHandler(t *testing.T) {
+	cmd := Command{Project: 1, Class: 0, Cmd: 1}
+	d := NewDispatcher()
+
+	var n int
+	var off func()
+	off = d.On(cmd, func(Arguments) {
+		n++
+		off()
+	})
+
+	d.Handle(cmd, rawArgs{})
+	d.Handle(cmd, rawArgs{})
+	if n != 1 {
+		t.Errorf("handler called %v times, want 1", n)
+	}
+}
@@ -0,0 +1,261 @@
+package arsdk
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ListKind tells if a command is an item of a list or a map, as given
+// by the type attribute of the command in the xml. The lists are sent
+// as one command for every item.
+type ListKind uint8
+
+// The kinds of lists. The items of a map have a key, and an item
+// replaces the item with the same key. The items of a list are only
+// kept once if the same item is received several times.
+const (
+	NotListItem ListKind = iota
+	ListItem
+	MapItem
+)
+
+func (k ListKind) String() string {
+	switch k {
+	case NotListItem:
+		return "NOT_LIST_ITEM"
+	case ListItem:
+		return "LIST_ITEM"
+	case MapItem:
+		return "MAP_ITEM"
+	}
+	return fmt.Sprintf("ListKind(%d)", uint8(k))
+}
+
+// ListFlags are the list_flags argument of an item, which tells where
+// the item is in the list.
+type ListFlags uint8
+
+// The list flags of an item.
+const (
+	// ListFirst is set for the first item of a list, and the items
+	// received before are dropped.
+	ListFirst ListFlags = 1 << iota
+	// ListLast is set for the last item of a list, and the list is
+	// complete.
+	ListLast
+	// ListEmpty is set if the list is empty, and the other arguments
+	// of the item are not used.
+	ListEmpty
+	// ListRemove is set if the item should be removed from the list.
+	ListRemove
+)
+
+// ListItemArguments is implemented by the generated argument types of
+// the commands which are an item of a list or a map. The arguments
+// with a list_flags argument also have an ItemFlags method returning
+// the flags.
+type ListItemArguments interface {
+	Arguments
+	ItemKind() ListKind
+	// ItemKey will return the key of the item in a map, or the item
+	// without its list flags for an item of a list.
+	ItemKey() interface{}
+}
+
+// ListKindOf will return the kind of list the arguments are an item of,
+// or NotListItem if they are not an item of a list.
+func ListKindOf(args Arguments) ListKind {
+	if a, ok := args.(ListItemArguments); ok {
+		return a.ItemKind()
+	}
+	return NotListItem
+}
+
+// ListFlagsOf will return the list flags of the arguments, and false
+// if the arguments have no list flags. The items of the older projects
+// have no list flags, and every item is sent on its own.
+func ListFlagsOf(args Arguments) (ListFlags, bool) {
+	if a, ok := args.(interface{ ItemFlags() ListFlags }); ok {
+		return a.ItemFlags(), true
+	}
+	return 0, false
+}
+
+// List is a list or a map assembled from the items received for a
+// command.
+type List struct {
+	Command Command
+	Kind    ListKind
+	// Items are the items in the order they were first received.
+	Items []Arguments
+	// Keys are the keys of the items, in the same order as Items.
+	Keys []interface{}
+}
+
+// Map will return the items of the list by their key.
+func (l List) Map() map[interface{}]Arguments {
+	m := make(map[interface{}]Arguments, len(l.Items))
+	for i, v := range l.Items {
+		m[l.Keys[i]] = v
+	}
+	return m
+}
+
+// Collector assembles the items of the lists and maps received as one
+// command for every item into complete lists, and calls the functions
+// subscribed to a command every time its list is complete.
+//
+// An item with ListFirst set starts a new list, which is complete when
+// an item with ListLast is received. The items received when no list is
+// being assembled update the last complete list, which is then complete
+// again. The items without list flags are always complete.
+//
+// A Collector is safe for concurrent use.
+type Collector struct {
+	mu    sync.Mutex
+	lists map[Command]*collectedList
+	subs  subscribers
+}
+
+// collectedList is the last complete list of a command, and the list
+// being assembled, which is nil if no list is being assembled. done is
+// false until a list of the command was complete.
+type collectedList struct {
+	kind     ListKind
+	complete *listItems
+	pending  *listItems
+	done     bool
+}
+
+// NewCollector will return a new Collector with no lists.
+func NewCollector() *Collector {
+	return &Collector{
+		lists: map[Command]*collectedList{},
+	}
+}
+
+// Add will add the arguments received for the command to its list, and
+// return false if the arguments are not an item of a list.
+// The functions subscribed to the command are called before Add returns
+// if the list is complete.
+func (c *Collector) Add(cmd Command, args Arguments) bool {
+	item, ok := args.(ListItemArguments)
+	if !ok || item.ItemKind() == NotListItem {
+		return false
+	}
+
+	c.mu.Lock()
+	l, ok := c.lists[cmd]
+	if !ok {
+		l = &collectedList{kind: item.ItemKind(), complete: &listItems{}}
+		c.lists[cmd] = l
+	}
+
+	flags, hasFlags := ListFlagsOf(args)
+	switch {
+	case !hasFlags:
+		l.complete.put(item.ItemKey(), args)
+	case flags&ListEmpty != 0:
+		l.complete = &listItems{}
+		l.pending = nil
+	default:
+		if flags&ListFirst != 0 {
+			l.pending = &listItems{}
+		}
+		items := l.pending
+		if items == nil {
+			items = l.complete
+		}
+		if flags&ListRemove != 0 {
+			items.remove(item.ItemKey())
+		} else {
+			items.put(item.ItemKey(), args)
+		}
+		if l.pending != nil && flags&ListLast != 0 {
+			l.complete = l.pending
+			l.pending = nil
+		}
+	}
+
+	if l.pending != nil {
+		c.mu.Unlock()
+		return true
+	}
+	l.done = true
+	list := l.complete.list(cmd, l.kind)
+	c.mu.Unlock()
+
+	c.subs.call(cmd, list)
+
+	return true
+}
+
+// Handle will add the arguments with Add, so c can be given to
+// arnetworkal.Dispatch.
+func (c *Collector) Handle(cmd Command, args Arguments) {
+	c.Add(cmd, args)
+}
+
+// List will return the last complete list of the command, and false if
+// no list was complete yet.
+func (c *Collector) List(cmd Command) (List, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.lists[cmd]
+	if !ok || !l.done {
+		return List{}, false
+	}
+	return l.complete.list(cmd, l.kind), true
+}
+
+// Subscribe will call fn with the list of the command every time it is
+// complete, and return a function which ends the subscription.
+func (c *Collector) Subscribe(cmd Command, fn func(List)) (unsubscribe func()) {
+	return c.subs.add(&subscriber{cmd: cmd, fn: func(_ Command, v interface{}) { fn(v.(List)) }})
+}
+
+// listItems are the items of a list by their key, and the keys in the
+// order the items were first received.
+type listItems struct {
+	keys  []interface{}
+	items map[interface{}]Arguments
+}
+
+func (l *listItems) put(key interface{}, args Arguments) {
+	if l.items == nil {
+		l.items = map[interface{}]Arguments{}
+	}
+	if _, ok := l.items[key]; !ok {
+		l.keys = append(l.keys, key)
+	}
+	l.items[key] = args
+}
+
+func (l *listItems) remove(key interface{}) {
+	if _, ok := l.items[key]; !ok {
+		return
+	}
+	delete(l.items, key)
+	for i, v := range l.keys {
+		if v == key {
+			l.keys = append(l.keys[:i:i], l.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// list will return a copy of the items as a List.
+func (l *listItems) list(cmd Command, kind ListKind) List {
+	list := List{
+		Command: cmd,
+		Kind:    kind,
+		Items:   make([]Arguments, len(l.keys)),
+		Keys:    make([]interface{}, len(l.keys)),
+	}
+	for i, k := range l.keys {
+		list.Items[i] = l.items[k]
+		list.Keys[i] = k
+	}
+	return list
+}
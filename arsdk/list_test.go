@@ -0,0 +1,120 @@
+package arsdk
+
+import (
+	"reflect"
+	"testing"
+)
+
+// mapItem are the arguments of an item of a map with list flags.
+type mapItem struct {
+	Key   string
+	Value int
+	Flags ListFlags
+}
+
+func (a mapItem) Encode(b []byte) []byte { return b }
+func (a mapItem) ItemKind() ListKind     { return MapItem }
+func (a mapItem) ItemKey() interface{}   { return a.Key }
+func (a mapItem) ItemFlags() ListFlags   { return a.Flags }
+
+// oldItem are the arguments of an item of a map without list flags,
+// like in the older projects.
+type oldItem struct {
+	ID    uint8
+	Value int
+}
+
+func (a oldItem) Encode(b []byte) []byte { return b }
+func (a oldItem) ItemKind() ListKind     { return MapItem }
+func (a oldItem) ItemKey() interface{}   { return a.ID }
+
+func TestCollector(t *testing.T) {
+	cmd := Command{Project: 135, Cmd: 2}
+	c := NewCollector()
+
+	var got []List
+	unsubscribe := c.Subscribe(cmd, func(l List) {
+		got = append(got, l)
+	})
+
+	steps := []struct {
+		args Arguments
+		// want is the list expected to be complete after the item, if
+		// any.
+		want []Arguments
+	}{
+		{args: mapItem{Key: "a", Value: 1, Flags: ListFirst}},
+		{args: mapItem{Key: "b", Value: 2}},
+		{
+			args: mapItem{Key: "a", Value: 3, Flags: ListLast},
+			want: []Arguments{mapItem{Key: "a", Value: 3, Flags: ListLast}, mapItem{Key: "b", Value: 2}},
+		},
+		// An item received when no list is assembled updates the list.
+		{
+			args: mapItem{Key: "a", Flags: ListRemove},
+			want: []Arguments{mapItem{Key: "b", Value: 2}},
+		},
+		{args: mapItem{Key: "c", Value: 4, Flags: ListFirst}},
+		{
+			args: mapItem{Flags: ListEmpty},
+			want: []Arguments{},
+		},
+		{
+			args: mapItem{Key: "d", Value: 5, Flags: ListFirst | ListLast},
+			want: []Arguments{mapItem{Key: "d", Value: 5, Flags: ListFirst | ListLast}},
+		},
+	}
+
+	for i, s := range steps {
+		got = nil
+		if !c.Add(cmd, s.args) {
+			t.Fatalf("step %v: Add returned false for a list item", i)
+		}
+		if s.want == nil {
+			if len(got) != 0 {
+				t.Errorf("step %v: got list %+v, want none", i, got)
+			}
+			continue
+		}
+		if len(got) != 1 || got[0].Command != cmd || got[0].Kind != MapItem || !reflect.DeepEqual(got[0].Items, s.want) {
+			t.Errorf("step %v: got lists %+v, want items %+v", i, got, s.want)
+		}
+	}
+
+	l, ok := c.List(cmd)
+	if !ok || !reflect.DeepEqual(l.Map(), map[interface{}]Arguments{"d": mapItem{Key: "d", Value: 5, Flags: ListFirst | ListLast}}) {
+		t.Errorf("got list %+v, %v", l, ok)
+	}
+
+	unsubscribe()
+	got = nil
+	c.Add(cmd, mapItem{Key: "e", Flags: ListFirst | ListLast})
+	if len(got) != 0 {
+		t.Errorf("got lists %+v after unsubscribe", got)
+	}
+}
+
+func TestCollectorNoFlags(t *testing.T) {
+	cmd := Command{Project: 0, Class: 15, Cmd: 0}
+	c := NewCollector()
+
+	if _, ok := c.List(cmd); ok {
+		t.Errorf("got a list before any item was added")
+	}
+
+	var n int
+	c.Subscribe(cmd, func(List) { n++ })
+	c.Add(cmd, oldItem{ID: 1, Value: 1})
+	c.Add(cmd, oldItem{ID: 2, Value: 2})
+	c.Add(cmd, oldItem{ID: 1, Value: 3})
+
+	l, _ := c.List(cmd)
+	want := []interface{}{uint8(1), uint8(2)}
+	if n != 3 || !reflect.DeepEqual(l.Keys, want) || l.Items[0] != (oldItem{ID: 1, Value: 3}) {
+		t.Errorf("got list %+v after %v notifications", l, n)
+	}
+
+	if c.Add(cmd, rawArgs{}) {
+		t.Errorf("Add returned true for arguments which are not a list item")
+	}
+}
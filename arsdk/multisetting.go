@@ -0,0 +1,82 @@
+package arsdk
+
+import "encoding/binary"
+
+// A multisetting argument holds several commands, so they can be sent
+// together in a single command. It is sent as its size in bytes as an
+// u16, followed by the members which are set. Every member is sent as
+// the size of the command in bytes as an u16, followed by the command
+// with its project, class and command ids and its arguments, like in
+// the payload of a frame:
+//
+//	size u16 | member size u16 | project u8 | class u8 | cmd u16 | args | member size u16 | ...
+
+// multisettingHeaderSize is the size of the project, class and command
+// ids of a member.
+const multisettingHeaderSize = 4
+
+// MultisettingMember is a command within a multisetting argument.
+type MultisettingMember struct {
+	Command Command
+	// Payload is the encoded arguments of the command.
+	Payload []byte
+}
+
+// AppendMultisettingMember will append the command with the arguments
+// given to the members of a multisetting, and return the extended
+// slice.
+func AppendMultisettingMember(members []byte, cmd Command, args Encoder) []byte {
+	start := len(members)
+	members = append(members, 0, 0)
+	members = append(members, byte(cmd.Project), byte(cmd.Class), byte(cmd.Cmd), byte(cmd.Cmd>>8))
+	members = args.Encode(members)
+	binary.LittleEndian.PutUint16(members[start:], uint16(len(members)-start-2))
+
+	return members
+}
+
+// AppendMultisetting will append the multisetting with the members
+// created with AppendMultisettingMember to b, and return the extended
+// slice.
+func AppendMultisetting(b []byte, members []byte) []byte {
+	b = append(b, byte(len(members)), byte(len(members)>>8))
+	return append(b, members...)
+}
+
+// DecodeMultisetting will decode the members of the multisetting in the
+// start of b, and return them together with the number of bytes used.
+// The payloads of the members refer to b.
+func DecodeMultisetting(b []byte) ([]MultisettingMember, int, error) {
+	if len(b) < 2 {
+		return nil, 0, ErrShortPayload
+	}
+	size := int(binary.LittleEndian.Uint16(b))
+	if len(b) < 2+size {
+		return nil, 0, ErrShortPayload
+	}
+
+	var members []MultisettingMember
+	data := b[2 : 2+size]
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, 0, ErrShortPayload
+		}
+		n := int(binary.LittleEndian.Uint16(data))
+		data = data[2:]
+		if n < multisettingHeaderSize || len(data) < n {
+			return nil, 0, ErrShortPayload
+		}
+
+		members = append(members, MultisettingMember{
+			Command: Command{
+				Project: ProjectDef(data[0]),
+				Class:   ClassDef(data[1]),
+				Cmd:     CmdDef(binary.LittleEndian.Uint16(data[2:4])),
+			},
+			Payload: data[multisettingHeaderSize:n],
+		})
+		data = data[n:]
+	}
+
+	return members, 2 + size, nil
+}
@@ -0,0 +1,77 @@
+package arsdk
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// rawArgs are arguments already encoded.
+type rawArgs []byte
+
+func (r rawArgs) Encode(b []byte) []byte {
+	return append(b, r...)
+}
+
+func TestMultisetting(t *testing.T) {
+	altitude := Command{Project: 1, Class: 6, Cmd: 0}
+	tilt := Command{Project: 1, Class: 6, Cmd: 1}
+
+	var members []byte
+	members = AppendMultisettingMember(members, altitude, rawArgs{0x00, 0x00, 0x20, 0x41})
+	members = AppendMultisettingMember(members, tilt, rawArgs{})
+	b := AppendMultisetting([]byte{0xff}, members)
+
+	want := []byte{
+		0xff,
+		// The size of the multisetting.
+		16, 0,
+		// The size of the member, the ids and the arguments.
+		8, 0, 1, 6, 0, 0, 0x00, 0x00, 0x20, 0x41,
+		4, 0, 1, 6, 1, 0,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got % x, want % x", b, want)
+	}
+
+	got, n, err := DecodeMultisetting(append(b[1:], 0xee))
+	if err != nil {
+		t.Fatalf("DecodeMultisetting: %v", err)
+	}
+	if n != len(b)-1 {
+		t.Errorf("got %v bytes used, want %v", n, len(b)-1)
+	}
+	wantMembers := []MultisettingMember{
+		{Command: altitude, Payload: []byte{0x00, 0x00, 0x20, 0x41}},
+		{Command: tilt, Payload: []byte{}},
+	}
+	if !reflect.DeepEqual(got, wantMembers) {
+		t.Errorf("got members %+v, want %+v", got, wantMembers)
+	}
+}
+
+func TestDecodeMultisettingEmpty(t *testing.T) {
+	got, n, err := DecodeMultisetting([]byte{0, 0})
+	if err != nil || n != 2 || len(got) != 0 {
+		t.Errorf("got %v, %v, %v, want no members and 2 bytes used", got, n, err)
+	}
+}
+
+func TestDecodeMultisettingShort(t *testing.T) {
+	for _, b := range [][]byte{
+		{},
+		{4},
+		// The multisetting is longer than the payload.
+		{6, 0, 4, 0, 1, 6},
+		// The member is longer than the multisetting.
+		{6, 0, 5, 0, 1, 6, 0, 0},
+		// The member is too short to hold the ids.
+		{4, 0, 2, 0, 1, 6},
+	} {
+		_, _, err := DecodeMultisetting(b)
+		if !errors.Is(err, ErrShortPayload) {
+			t.Errorf("% x: got error %v, want %v", b, err, ErrShortPayload)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package arsdk
+
+import "reflect"
+
+// CommandInfo is a command together with what the xml tells about it.
+type CommandInfo struct {
+	Command Command
+	// Name is the name of the command with the names of its project and
+	// class, like ardrone3.Piloting.TakeOff, or like camera.set_mode for
+	// the message of a feature.
+	Name    string
+	Decoder Decoder
+	// Args is the zero value of the arguments of the command.
+	Args Arguments
+	// Event is true if the command is an event sent by the drone, like
+	// the commands of the classes named like PilotingState and the
+	// events of a feature.
+	Event      bool
+	Deprecated bool
+	// State is true if the command is an event kept by a State.
+	State bool
+}
+
+// Registry finds the commands by their name, their ids or the type of
+// their arguments. The generated code has a Registry with all the
+// commands created.
+type Registry struct {
+	commands  []CommandInfo
+	byName    map[string]int
+	byCommand map[Command]int
+	byType    map[reflect.Type]int
+}
+
+// NewRegistry will return a new Registry with the commands given, which
+// are normally the Commands of the generated code. If two commands have
+// the same name, ids or arguments type, the last one is found.
+func NewRegistry(commands ...[]CommandInfo) *Registry {
+	r := &Registry{
+		byName:    map[string]int{},
+		byCommand: map[Command]int{},
+		byType:    map[reflect.Type]int{},
+	}
+
+	for _, list := range commands {
+		for _, c := range list {
+			i := len(r.commands)
+			r.commands = append(r.commands, c)
+			r.byName[c.Name] = i
+			r.byCommand[c.Command] = i
+			if c.Args != nil {
+				r.byType[reflect.TypeOf(c.Args)] = i
+			}
+		}
+	}
+
+	return r
+}
+
+// ByName will return the command with the name given, like
+// ardrone3.Piloting.TakeOff, and false if there is no such command.
+func (r *Registry) ByName(name string) (CommandInfo, bool) {
+	i, ok := r.byName[name]
+	return r.lookup(i, ok)
+}
+
+// ByID will return the command with the project, class and command ids
+// given, and false if there is no such command.
+func (r *Registry) ByID(project ProjectDef, class ClassDef, cmd CmdDef) (CommandInfo, bool) {
+	return r.ByCommand(Command{Project: project, Class: class, Cmd: cmd})
+}
+
+// ByCommand will return the command, and false if there is no such
+// command.
+func (r *Registry) ByCommand(cmd Command) (CommandInfo, bool) {
+	i, ok := r.byCommand[cmd]
+	return r.lookup(i, ok)
+}
+
+// ByArguments will return the command the arguments belong to, found by
+// the type of the arguments, and false if the type is not known. A
+// pointer to the arguments can also be given.
+func (r *Registry) ByArguments(args Arguments) (CommandInfo, bool) {
+	t := reflect.TypeOf(args)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	i, ok := r.byType[t]
+	return r.lookup(i, ok)
+}
+
+// Range will call fn for every command in the order they were given to
+// NewRegistry, until fn returns false.
+func (r *Registry) Range(fn func(CommandInfo) bool) {
+	for _, c := range r.commands {
+		if !fn(c) {
+			return
+		}
+	}
+}
+
+// Len will return the number of commands in the registry.
+func (r *Registry) Len() int {
+	return len(r.commands)
+}
+
+func (r *Registry) lookup(i int, ok bool) (CommandInfo, bool) {
+	if !ok {
+		return CommandInfo{}, false
+	}
+	return r.commands[i], true
+}
@@ -0,0 +1,56 @@
+package arsdk
+
+import "testing"
+
+// takeOffArgs and pcmdArgs are the arguments of test commands.
+type takeOffArgs struct{}
+
+func (a takeOffArgs) Encode(b []byte) []byte { return b }
+
+type pcmdArgs struct {
+	Roll int8
+}
+
+func (a pcmdArgs) Encode(b []byte) []byte { return append(b, byte(a.Roll)) }
+
+func TestRegistry(t *testing.T) {
+	takeOff := CommandInfo{Command: Command{Project: 1, Class: 0, Cmd: 1}, Name: "ardrone3.Piloting.TakeOff", Args: takeOffArgs{}}
+	pcmd := CommandInfo{Command: Command{Project: 1, Class: 0, Cmd: 2}, Name: "ardrone3.Piloting.PCMD", Args: pcmdArgs{}}
+	scan := CommandInfo{Command: Command{Project: 135, Class: 0, Cmd: 1}, Name: "wifi.scan"}
+	r := NewRegistry([]CommandInfo{takeOff, pcmd}, []CommandInfo{scan})
+
+	if c, ok := r.ByName("ardrone3.Piloting.PCMD"); !ok || c.Command != pcmd.Command {
+		t.Errorf("ByName: got %+v, %v", c, ok)
+	}
+	if c, ok := r.ByID(135, 0, 1); !ok || c.Name != "wifi.scan" {
+		t.Errorf("ByID: got %+v, %v", c, ok)
+	}
+	if c, ok := r.ByArguments(pcmdArgs{Roll: 10}); !ok || c.Name != pcmd.Name {
+		t.Errorf("ByArguments: got %+v, %v", c, ok)
+	}
+	if c, ok := r.ByArguments(&takeOffArgs{}); !ok || c.Name != takeOff.Name {
+		t.Errorf("ByArguments with a pointer: got %+v, %v", c, ok)
+	}
+
+	if _, ok := r.ByName("ardrone3.Piloting"); ok {
+		t.Errorf("ByName found a command for a class")
+	}
+	if _, ok := r.ByID(1, 0, 9); ok {
+		t.Errorf("ByID found an unknown command")
+	}
+	if _, ok := r.ByArguments(rawArgs{}); ok {
+		t.Errorf("ByArguments found an unknown type")
+	}
+	if _, ok := r.ByArguments(nil); ok {
+		t.Errorf("ByArguments found a command for nil")
+	}
+
+	var names []string
+	r.Range(func(c CommandInfo) bool {
+		names = append(names, c.Name)
+		return len(names) < 2
+	})
+	if len(names) != 2 || names[0] != takeOff.Name || names[1] != pcmd.Name || r.Len() != 3 {
+		t.Errorf("Range: got %v of %v commands", names, r.Len())
+	}
+}
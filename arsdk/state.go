@@ -0,0 +1,102 @@
+package arsdk
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Handler is given the commands decoded from the frames received, and
+// is normally a State or a Collector given to arnetworkal.Dispatch.
+type Handler interface {
+	Handle(cmd Command, args Arguments)
+}
+
+// State holds the last arguments received for every state event, like
+// the battery level or the flying state of the drone, and calls the
+// functions subscribed every time a state changes. The generated code
+// wraps it in a State type with a typed method for every state event.
+//
+// A State is safe for concurrent use.
+type State struct {
+	mu       sync.RWMutex
+	commands map[Command]bool
+	values   map[Command]Arguments
+	// encoded are the encoded arguments of values, which are compared
+	// to find out if a state changed.
+	encoded map[Command][]byte
+	subs    subscribers
+}
+
+// NewState will return a new State keeping the commands given, which is
+// normally the StateCommands of the generated code.
+func NewState(commands map[Command]bool) *State {
+	return &State{
+		commands: commands,
+		values:   map[Command]Arguments{},
+		encoded:  map[Command][]byte{},
+	}
+}
+
+// Update will store the arguments received for the command, and return
+// false if the command is not a state kept by s. The functions
+// subscribed are called before Update returns if the state changed.
+func (s *State) Update(cmd Command, args Arguments) bool {
+	if !s.commands[cmd] {
+		return false
+	}
+	encoded := args.Encode(nil)
+
+	s.mu.Lock()
+	old, ok := s.encoded[cmd]
+	if ok && bytes.Equal(old, encoded) {
+		s.mu.Unlock()
+		return true
+	}
+	s.values[cmd] = args
+	s.encoded[cmd] = encoded
+	s.mu.Unlock()
+
+	s.subs.call(cmd, args)
+
+	return true
+}
+
+// Handle will store the arguments with Update, so s can be given to
+// arnetworkal.Dispatch.
+func (s *State) Handle(cmd Command, args Arguments) {
+	s.Update(cmd, args)
+}
+
+// Get will return the last arguments received for the command, or nil
+// if none were received.
+func (s *State) Get(cmd Command) Arguments {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.values[cmd]
+}
+
+// Snapshot will return a copy of all the states received.
+func (s *State) Snapshot() map[Command]Arguments {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m := make(map[Command]Arguments, len(s.values))
+	for k, v := range s.values {
+		m[k] = v
+	}
+	return m
+}
+
+// Subscribe will call fn with the new arguments every time the state of
+// the command changes, and return a function which ends the
+// subscription.
+func (s *State) Subscribe(cmd Command, fn func(Arguments)) (unsubscribe func()) {
+	return s.subs.add(&subscriber{cmd: cmd, fn: withArguments(func(_ Command, args Arguments) { fn(args) })})
+}
+
+// SubscribeAll will call fn every time a state changes, and return a
+// function which ends the subscription.
+func (s *State) SubscribeAll(fn func(Command, Arguments)) (unsubscribe func()) {
+	return s.subs.add(&subscriber{all: true, fn: withArguments(fn)})
+}
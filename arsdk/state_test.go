@@ -0,0 +1,59 @@
+package arsdk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestState(t *testing.T) {
+	battery := Command{Project: 0, Class: 5, Cmd: 1}
+	flying := Command{Project: 1, Class: 4, Cmd: 1}
+	takeOff := Command{Project: 1, Class: 0, Cmd: 1}
+	s := NewState(map[Command]bool{battery: true, flying: true})
+
+	var got []Arguments
+	unsubscribe := s.Subscribe(battery, func(args Arguments) {
+		got = append(got, args)
+	})
+	var all []Command
+	s.SubscribeAll(func(cmd Command, _ Arguments) {
+		all = append(all, cmd)
+	})
+
+	if s.Update(takeOff, rawArgs{}) {
+		t.Errorf("Update returned true for a command which is not a state")
+	}
+	if s.Get(battery) != nil {
+		t.Errorf("got a state before any was received")
+	}
+
+	s.Update(battery, rawArgs{90})
+	s.Update(flying, rawArgs{1})
+	// The functions are only called when the state changes.
+	s.Update(battery, rawArgs{90})
+	s.Update(battery, rawArgs{89})
+
+	want := []Arguments{rawArgs{90}, rawArgs{89}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(all, []Command{battery, flying, battery}) {
+		t.Errorf("got changes %v for all the states", all)
+	}
+	if !reflect.DeepEqual(s.Get(battery), rawArgs{89}) {
+		t.Errorf("got state %v", s.Get(battery))
+	}
+
+	snapshot := s.Snapshot()
+	s.Handle(flying, rawArgs{2})
+	if !reflect.DeepEqual(snapshot, map[Command]Arguments{battery: rawArgs{89}, flying: rawArgs{1}}) {
+		t.Errorf("got snapshot %v", snapshot)
+	}
+
+	unsubscribe()
+	got = nil
+	s.Update(battery, rawArgs{88})
+	if len(got) != 0 {
+		t.Errorf("got %v after unsubscribe", got)
+	}
+}
@@ -0,0 +1,398 @@
+/*
+Package arsdktest checks that the expectations given in the xml for a
+command are met by the events received after the command was sent.
+
+The events are normally decoded from the frames received from a real or
+a simulated drone with Events, and the expectations of the command sent
+are checked with Check or Expect:
+
+	m.SendCommand(ctx, arsdk.BufferOf(PilotingTakeOff), arsdk.TimeoutOf(PilotingTakeOff), payload)
+	arsdktest.Expect(t, PilotingTakeOff, Ardrone3PilotingTakeOffArguments{}, events, arsdktest.DefaultDeadlines)
+
+The immediate expectations must be met in order within the immediate
+deadline, and the delayed expectations in order within the delayed
+deadline, both counted from when the check was started. An event can
+only meet a single expectation.
+
+The arguments of the events are found by the names they have in the xml,
+which the generated argument structs have as an arsdk struct tag.
+*/
+package arsdktest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/postmannen/lexmlparser/arnetworkal"
+	"github.com/postmannen/lexmlparser/arsdk"
+)
+
+// Event is a message received after a command was sent.
+type Event struct {
+	Command arsdk.Command
+	Args    arsdk.Arguments
+	// Err is the error if the payload of the frame could not be decoded.
+	Err error
+}
+
+func (e Event) String() string {
+	ref := fmt.Sprintf("#%v-%v-%v", e.Command.Project, e.Command.Class, e.Command.Cmd)
+	if e.Err != nil {
+		return fmt.Sprintf("%v: %v", ref, e.Err)
+	}
+	return ref + formatArgs(e.Args)
+}
+
+// Deadlines are how long to wait for the expectations to be met.
+type Deadlines struct {
+	Immediate time.Duration
+	Delayed   time.Duration
+}
+
+// DefaultDeadlines are the deadlines used if no other are known.
+var DefaultDeadlines = Deadlines{Immediate: 2 * time.Second, Delayed: 30 * time.Second}
+
+// Events will decode the data frames received with the decoders found
+// in commands, which is normally the CommandMap of the generated code.
+// The channel returned is closed when frames is closed, and must be
+// read until then.
+func Events(commands map[arsdk.Command]arsdk.Decoder, frames <-chan arnetworkal.Frame) <-chan Event {
+	out := make(chan Event)
+
+	go func() {
+		defer close(out)
+		for f := range frames {
+			if f.DataType == arnetworkal.DataTypeAck {
+				continue
+			}
+			cmd, args, err := arnetworkal.Dispatch(commands, f.Payload)
+			out <- Event{Command: cmd, Args: args, Err: err}
+		}
+	}()
+
+	return out
+}
+
+// Expect will check the expectations of cmd with Check, and report an
+// error to t if they were not met.
+func Expect(t testing.TB, cmd arsdk.Decoder, sent arsdk.Arguments, events <-chan Event, d Deadlines) {
+	t.Helper()
+
+	if err := Check(cmd, sent, events, d); err != nil {
+		t.Error(err)
+	}
+}
+
+// check is the state of checking a single expectation.
+type check struct {
+	exp     arsdk.Expectation
+	delayed bool
+	// met is the index of the event which met the expectation, or -1.
+	met int
+}
+
+// Check will read the events until the expectations of cmd sent with
+// the arguments sent are met, or the deadlines are passed. The error
+// returned lists the expectations not met together with the events
+// received.
+func Check(cmd arsdk.Decoder, sent arsdk.Arguments, events <-chan Event, d Deadlines) error {
+	var immediate, delayed []*check
+	for _, e := range arsdk.ExpectationsOf(cmd) {
+		c := &check{exp: e, delayed: e.Delayed, met: -1}
+		if e.Delayed {
+			delayed = append(delayed, c)
+		} else {
+			immediate = append(immediate, c)
+		}
+	}
+
+	start := time.Now()
+	deadlines := map[bool]time.Time{
+		false: start.Add(d.Immediate),
+		true:  start.Add(d.Delayed),
+	}
+
+	var received []Event
+	// next is the index of the next expectation to meet for the
+	// immediate and the delayed expectations.
+	next := map[bool]int{}
+	lists := map[bool][]*check{false: immediate, true: delayed}
+	pending := func(delayed bool) bool {
+		return next[delayed] < len(lists[delayed]) && time.Now().Before(deadlines[delayed])
+	}
+	ended := false
+
+	for !ended && (pending(false) || pending(true)) {
+		deadline := deadlines[true]
+		if pending(false) && deadlines[false].Before(deadline) || !pending(true) {
+			deadline = deadlines[false]
+		}
+		timer := time.NewTimer(time.Until(deadline))
+
+		select {
+		case e, ok := <-events:
+			timer.Stop()
+			if !ok {
+				ended = true
+				break
+			}
+			received = append(received, e)
+
+			for _, delayed := range []bool{false, true} {
+				if !pending(delayed) {
+					continue
+				}
+				c := lists[delayed][next[delayed]]
+				if meets(c.exp, e, sent) {
+					c.met = len(received) - 1
+					next[delayed]++
+					break
+				}
+			}
+		case <-timer.C:
+		}
+	}
+
+	if next[false] == len(immediate) && next[true] == len(delayed) {
+		return nil
+	}
+
+	return &checkError{
+		cmd:       typeName(cmd),
+		sent:      sent,
+		checks:    append(immediate, delayed...),
+		events:    received,
+		ended:     ended,
+		deadlines: d,
+	}
+}
+
+// checkError is returned by Check when the expectations of a command
+// were not met, and reports what was expected and what was received.
+type checkError struct {
+	cmd       string
+	sent      arsdk.Arguments
+	checks    []*check
+	events    []Event
+	ended     bool
+	deadlines Deadlines
+}
+
+func (e *checkError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "expectations of %v not met:\n", e.cmd)
+
+	// Only the first expectation not met of each kind have its candidates
+	// listed, since the expectations after it were not looked for. The
+	// candidates are the events received after the event meeting the
+	// expectation before it.
+	explained := map[bool]bool{}
+	from := map[bool]int{}
+	for _, c := range e.checks {
+		kind := "immediate"
+		deadline := e.deadlines.Immediate
+		if c.delayed {
+			kind = "delayed"
+			deadline = e.deadlines.Delayed
+		}
+
+		if c.met != -1 {
+			fmt.Fprintf(&b, "  %v %v: met by event %v\n", kind, formatExpectation(c.exp, e.sent), c.met)
+			from[c.delayed] = c.met + 1
+			continue
+		}
+
+		reason := fmt.Sprintf("not met within %v", deadline)
+		if e.ended {
+			reason = "not met before the events ended"
+		}
+		fmt.Fprintf(&b, "  %v %v: %v\n", kind, formatExpectation(c.exp, e.sent), reason)
+
+		if explained[c.delayed] {
+			continue
+		}
+		explained[c.delayed] = true
+		for i := from[c.delayed]; i < len(e.events); i++ {
+			ev := e.events[i]
+			for _, m := range c.exp.OneOf {
+				if ev.Err != nil || ev.Command != m.Command {
+					continue
+				}
+				fmt.Fprintf(&b, "    event %v %v: %v\n", i, ev, strings.Join(mismatches(m, ev, e.sent), ", "))
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "events received:\n")
+	if len(e.events) == 0 {
+		fmt.Fprintf(&b, "  none\n")
+	}
+	for i, ev := range e.events {
+		fmt.Fprintf(&b, "  %v: %v\n", i, ev)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// meets will return true if the event meets any of the messages of the
+// expectation.
+func meets(exp arsdk.Expectation, e Event, sent arsdk.Arguments) bool {
+	if e.Err != nil {
+		return false
+	}
+	for _, m := range exp.OneOf {
+		if m.Command == e.Command && len(mismatches(m, e, sent)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// mismatches will return a description of every argument of the event
+// not having the value expected.
+func mismatches(m arsdk.ExpectedMsg, e Event, sent arsdk.Arguments) []string {
+	var s []string
+
+	for _, p := range m.Args {
+		got, ok := argValue(e.Args, p.Name)
+		if !ok {
+			s = append(s, fmt.Sprintf("%v: no such argument", p.Name))
+			continue
+		}
+
+		if p.This == "" {
+			if !strings.EqualFold(fmt.Sprint(got.Interface()), p.Value) {
+				s = append(s, fmt.Sprintf("%v: got %v, want %v", p.Name, got.Interface(), p.Value))
+			}
+			continue
+		}
+
+		want, ok := argValue(sent, p.This)
+		if !ok {
+			s = append(s, fmt.Sprintf("%v: no argument %v in the command sent", p.Name, p.This))
+			continue
+		}
+		if !equal(got, want) {
+			s = append(s, fmt.Sprintf("%v: got %v, want %v", p.Name, got.Interface(), want.Interface()))
+		}
+	}
+
+	return s
+}
+
+// argValue will return the value of the argument with the given xml
+// name, found by the arsdk struct tag of the fields.
+func argValue(args interface{}, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(args)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("arsdk") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// equal will compare the values of two arguments. The arguments can
+// have different types, like an u8 in the command sent and an enum in
+// the event, so numbers are compared by their value.
+func equal(a, b reflect.Value) bool {
+	if x, ok := intValue(a); ok {
+		if y, ok := intValue(b); ok {
+			return x == y
+		}
+	}
+	if isFloat(a) && isFloat(b) {
+		return a.Float() == b.Float()
+	}
+	return fmt.Sprint(a.Interface()) == fmt.Sprint(b.Interface())
+}
+
+func intValue(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), true
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func isFloat(v reflect.Value) bool {
+	return v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+// formatArgs will format the arguments like (name: value, ...) with
+// the xml names of the arguments.
+func formatArgs(args arsdk.Arguments) string {
+	v := reflect.ValueOf(args)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || v.NumField() == 0 {
+		return ""
+	}
+
+	var s []string
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("arsdk")
+		if name == "" {
+			name = v.Type().Field(i).Name
+		}
+		s = append(s, fmt.Sprintf("%v: %v", name, v.Field(i).Interface()))
+	}
+	return "(" + strings.Join(s, ", ") + ")"
+}
+
+// formatExpectation will format the expectation like it is written in
+// the xml, with the values of the arguments of the command sent.
+func formatExpectation(exp arsdk.Expectation, sent arsdk.Arguments) string {
+	var msgs []string
+	for _, m := range exp.OneOf {
+		ref := m.Ref
+		if ref == "" {
+			ref = fmt.Sprintf("#%v-%v-%v", m.Command.Project, m.Command.Class, m.Command.Cmd)
+		}
+
+		var args []string
+		for _, p := range m.Args {
+			if p.This == "" {
+				args = append(args, fmt.Sprintf("%v: %v", p.Name, p.Value))
+				continue
+			}
+			v, ok := argValue(sent, p.This)
+			if !ok {
+				args = append(args, fmt.Sprintf("%v: this.%v", p.Name, p.This))
+				continue
+			}
+			args = append(args, fmt.Sprintf("%v: this.%v = %v", p.Name, p.This, v.Interface()))
+		}
+		if len(args) != 0 {
+			ref += "(" + strings.Join(args, ", ") + ")"
+		}
+		msgs = append(msgs, ref)
+	}
+	return strings.Join(msgs, " | ")
+}
+
+// typeName will return the name of the type of v without the package
+// name.
+func typeName(v interface{}) string {
+	s := fmt.Sprintf("%T", v)
+	return s[strings.LastIndex(s, ".")+1:]
+}
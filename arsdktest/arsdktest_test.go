@@ -0,0 +1,165 @@
+package arsdktest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/postmannen/lexmlparser/arnetworkal"
+	"github.com/postmannen/lexmlparser/arsdk"
+)
+
+// flyingState is an enum like the ones created by the generator.
+type flyingState uint32
+
+func (e flyingState) String() string {
+	return []string{"landed", "takingoff", "hovering", "flying", "landing", "emergency", "motor_ramping"}[e]
+}
+
+type flyingStateArguments struct {
+	State flyingState `arsdk:"state"`
+	Alert uint8       `arsdk:"alert"`
+}
+
+func (a flyingStateArguments) Encode(b []byte) []byte {
+	return append(b, byte(a.State), byte(a.State>>8), byte(a.State>>16), byte(a.State>>24), a.Alert)
+}
+
+type flyingStateCmd arsdk.Command
+
+func (c flyingStateCmd) Decode(b []byte) (arsdk.Arguments, error) {
+	if len(b) < 5 {
+		return nil, &arsdk.DecodeError{Cmd: "flyingStateCmd", Err: arsdk.ErrShortPayload}
+	}
+	return flyingStateArguments{State: flyingState(b[0]), Alert: b[4]}, nil
+}
+
+var flyingStateChanged = arsdk.Command{Project: 1, Class: 4, Cmd: 1}
+
+type takeOffArguments struct {
+	Alert uint8 `arsdk:"alert"`
+}
+
+func (a takeOffArguments) Encode(b []byte) []byte { return append(b, a.Alert) }
+
+// takeOff is a command expecting motor_ramping then takingoff, and the
+// alert sent to be repeated when it is flying.
+type takeOff struct{}
+
+func (takeOff) Decode(b []byte) (arsdk.Arguments, error) { return takeOffArguments{}, nil }
+
+func (takeOff) Expectations() []arsdk.Expectation {
+	return []arsdk.Expectation{
+		{OneOf: []arsdk.ExpectedMsg{{Ref: "#1-4-1", Command: flyingStateChanged, Args: []arsdk.ArgPredicate{{Name: "state", Value: "motor_ramping"}}}}},
+		{OneOf: []arsdk.ExpectedMsg{{Ref: "#1-4-1", Command: flyingStateChanged, Args: []arsdk.ArgPredicate{{Name: "state", Value: "takingoff"}}}}},
+		{Delayed: true, OneOf: []arsdk.ExpectedMsg{
+			{Ref: "#1-4-1", Command: flyingStateChanged, Args: []arsdk.ArgPredicate{{Name: "state", Value: "hovering"}, {Name: "alert", This: "alert"}}},
+			{Ref: "#1-4-1", Command: flyingStateChanged, Args: []arsdk.ArgPredicate{{Name: "state", Value: "flying"}, {Name: "alert", This: "alert"}}},
+		}},
+	}
+}
+
+var testDeadlines = Deadlines{Immediate: 50 * time.Millisecond, Delayed: 100 * time.Millisecond}
+
+// send will send the events on a channel, and close it after the last
+// one if end is true.
+func send(events []Event, end bool) <-chan Event {
+	ch := make(chan Event, len(events))
+	for _, e := range events {
+		ch <- e
+	}
+	if end {
+		close(ch)
+	}
+	return ch
+}
+
+func state(s flyingState, alert uint8) Event {
+	return Event{Command: flyingStateChanged, Args: flyingStateArguments{State: s, Alert: alert}}
+}
+
+func TestCheck(t *testing.T) {
+	events := send([]Event{
+		{Command: arsdk.Command{Project: 0, Class: 5, Cmd: 4}},
+		state(6, 0),
+		state(1, 0),
+		state(3, 2),
+	}, false)
+
+	Expect(t, takeOff{}, takeOffArguments{Alert: 2}, events, testDeadlines)
+}
+
+func TestCheckNotMet(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		end    bool
+		want   []string
+	}{
+		{
+			name:   "wrong order",
+			events: []Event{state(1, 0), state(6, 0)},
+			want: []string{
+				"expectations of takeOff not met:",
+				"  immediate #1-4-1(state: motor_ramping): met by event 1\n  immediate #1-4-1(state: takingoff): not met within 50ms\n  delayed",
+				"  delayed #1-4-1(state: hovering, alert: this.alert = 2) | #1-4-1(state: flying, alert: this.alert = 2): not met within 100ms",
+				"events received:\n  0: #1-4-1(state: takingoff, alert: 0)\n  1: #1-4-1(state: motor_ramping, alert: 0)",
+			},
+		},
+		{
+			name:   "wrong argument",
+			events: []Event{state(6, 0), state(1, 0), state(2, 1)},
+			end:    true,
+			want: []string{
+				"  immediate #1-4-1(state: motor_ramping): met by event 0",
+				"  immediate #1-4-1(state: takingoff): met by event 1",
+				": not met before the events ended",
+				"    event 2 #1-4-1(state: hovering, alert: 1): alert: got 1, want 2",
+				"    event 2 #1-4-1(state: hovering, alert: 1): state: got hovering, want flying, alert: got 1, want 2",
+			},
+		},
+		{
+			name:   "no events",
+			events: nil,
+			want:   []string{"events received:\n  none"},
+		},
+	}
+
+	for _, tt := range tests {
+		err := Check(takeOff{}, takeOffArguments{Alert: 2}, send(tt.events, tt.end), testDeadlines)
+		if err == nil {
+			t.Errorf("%v: got no error", tt.name)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(err.Error(), w) {
+				t.Errorf("%v: error is missing %q:\n%v", tt.name, w, err)
+			}
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	commands := map[arsdk.Command]arsdk.Decoder{flyingStateChanged: flyingStateCmd(flyingStateChanged)}
+
+	frames := make(chan arnetworkal.Frame, 3)
+	frames <- arnetworkal.Frame{DataType: arnetworkal.DataTypeAck, Payload: []byte{1}}
+	frames <- arnetworkal.Frame{DataType: arnetworkal.DataTypeDataWithAck, Payload: arnetworkal.EncodeCommand(nil, flyingStateChanged, flyingStateArguments{State: 2})}
+	frames <- arnetworkal.Frame{DataType: arnetworkal.DataTypeData, Payload: arnetworkal.EncodeCommand(nil, flyingStateChanged, nil)}
+	close(frames)
+
+	var events []Event
+	for e := range Events(commands, frames) {
+		events = append(events, e)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got %v events, want 2", len(events))
+	}
+	if got := events[0].String(); got != "#1-4-1(state: hovering, alert: 0)" {
+		t.Errorf("got event %v", got)
+	}
+	if events[1].Err == nil {
+		t.Errorf("got no error for the short payload")
+	}
+}
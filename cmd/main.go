@@ -7,10 +7,11 @@ import (
 	"flag"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/postmannen/lexmlparser"
-
-	"github.com/postmannen/lexml"
 )
 
 func main() {
@@ -23,21 +24,35 @@ func main() {
 
 	}
 
-	inFileName := flag.String("inFile", "", "file name to read from")
-	writeMode := flag.String("writeMode", "stdout", "stdout/file")
+	inFileName := flag.String("inFile", "", "comma separated list of xml files or directories to read from. More can be given as arguments after the flags")
+	writeMode := flag.String("writeMode", "stdout", "stdout/file/packages")
 	outFileName := flag.String("outFile", "", "file name to write to")
+	outDir := flag.String("outDir", "", "directory to write a package for each project to, with the packages writeMode")
+	importPath := flag.String("importPath", "", "import path of the -outDir directory, with the packages writeMode")
+	pkgName := flag.String("package", "main", "name of the package created, not used with the packages writeMode")
+	buildTags := flag.String("buildTags", "", "comma separated list of build tags all required to build the code created")
+	templatesDir := flag.String("templates", "", "directory with .tmpl files replacing the default templates with the same name")
+	noDeprecated := flag.Bool("noDeprecated", false, "exclude the commands marked as deprecated in the xml")
 
 	flag.Parse()
 
-	// Open the file to read from.
-	inFh, err := os.Open(*inFileName)
+	inFiles, err := inputFiles(append(strings.Split(*inFileName, ","), flag.Args()...))
 	if err != nil {
-		log.Fatal("Error: opening file: ", err)
+		log.Fatal("Error: finding input files: ", err)
+	}
+	if len(inFiles) == 0 {
+		log.Fatal("Specify an xml file\n")
 	}
 
 	var outFh *os.File
 
-	if *writeMode == "file" {
+	switch *writeMode {
+	case "packages":
+		if *outDir == "" || *importPath == "" {
+			log.Println("error: You have to specify the directory and its import path with the -outDir and -importPath parameters")
+			os.Exit(1)
+		}
+	case "file":
 		if *outFileName == "" {
 			log.Println("error: You have to specify a filename with the -outFile parameter")
 			os.Exit(1)
@@ -50,16 +65,81 @@ func main() {
 
 		defer outFh.Close()
 
-	} else {
+	default:
 		outFh = os.Stdout
 	}
 
-	// Start the lexer which will lex trough the xml file given
-	// as an input argument,
-	// and return tokens of what is being lexed back on a channel.
-	tCh := lexml.LexStart(inFh)
+	// Parse all the xml files given into a single protocol, so the
+	// commands of all the projects are generated into the same package.
+	proto, err := lexmlparser.ParseFiles(inFiles...)
+	if err != nil {
+		log.Fatal("Error: parsing: ", err)
+	}
+
+	if *noDeprecated {
+		proto.RemoveDeprecated()
+	}
+
+	// The checksum of the xml files is written in the header of the code,
+	// so it can be checked if the code was created from other xml.
+	checksum, err := lexmlparser.Checksum(inFiles...)
+	if err != nil {
+		log.Fatal("Error: creating checksum: ", err)
+	}
+	opts := lexmlparser.Options{
+		Package:  *pkgName,
+		Checksum: checksum,
+	}
+	if *templatesDir != "" {
+		opts.Templates, err = lexmlparser.LoadTemplates(*templatesDir)
+		if err != nil {
+			log.Fatal("Error: loading templates: ", err)
+		}
+	}
+	for _, v := range inFiles {
+		opts.Sources = append(opts.Sources, filepath.Base(v))
+	}
+	for _, v := range strings.Split(*buildTags, ",") {
+		if v != "" {
+			opts.BuildTags = append(opts.BuildTags, v)
+		}
+	}
+
+	if *writeMode == "packages" {
+		err = lexmlparser.GeneratePackages(*outDir, *importPath, proto, opts)
+	} else {
+		err = lexmlparser.Generate(outFh, proto, opts)
+	}
+	if err != nil {
+		log.Fatal("Error: generating: ", err)
+	}
+}
+
+// inputFiles will return the xml files to read from. The directories
+// given are replaced with the xml files found in them, sorted by name.
+func inputFiles(names []string) ([]string, error) {
+	var files []string
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, name)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(name, "*.xml"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
 
-	// Start the parser, and give it the token channel from
-	// the lexer as it's input.
-	lexmlparser.Start(tCh, outFh)
+	return files, nil
 }
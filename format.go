@@ -0,0 +1,132 @@
+package lexmlparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	goparser "go/parser"
+	"go/scanner"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxSourceErrors is the max number of errors reported when the code
+// created does not parse.
+const maxSourceErrors = 10
+
+// formatSource will remove the imports not used by the code, and format
+// it like gofmt. If the code is not valid Go, an error is returned with
+// the lines that could not be parsed.
+func formatSource(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "", src, goparser.ParseComments)
+	if err != nil {
+		return nil, sourceError(src, err)
+	}
+
+	removeUnusedImports(f)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// removeUnusedImports will remove the imports of the file that are not
+// referred to by the code. The blank and dot imports are kept.
+func removeUnusedImports(f *ast.File) {
+	// The identifiers not declared in the file, which are the package
+	// names when used as X in X.Y.
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+			used[id.Name] = true
+		}
+		return true
+	})
+
+	var decls []ast.Decl
+	for _, d := range f.Decls {
+		gen, ok := d.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			decls = append(decls, d)
+			continue
+		}
+
+		var specs []ast.Spec
+		for _, s := range gen.Specs {
+			spec := s.(*ast.ImportSpec)
+			p, _ := strconv.Unquote(spec.Path.Value)
+			name := path.Base(p)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			if name == "_" || name == "." || used[name] {
+				specs = append(specs, spec)
+			}
+		}
+		if len(specs) == 0 {
+			continue
+		}
+		gen.Specs = specs
+		decls = append(decls, gen)
+	}
+	f.Decls = decls
+
+	// The imports of the file are also listed in f.Imports, which is
+	// used by the printer.
+	var imports []*ast.ImportSpec
+	for _, spec := range f.Imports {
+		for _, d := range decls {
+			if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.IMPORT && hasSpec(gen, spec) {
+				imports = append(imports, spec)
+			}
+		}
+	}
+	f.Imports = imports
+}
+
+func hasSpec(gen *ast.GenDecl, spec *ast.ImportSpec) bool {
+	for _, s := range gen.Specs {
+		if s == spec {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceError will return an error listing the lines of src which could
+// not be parsed, together with the error for each of them.
+func sourceError(src []byte, err error) error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return fmt.Errorf("generated code does not parse: %v", err)
+	}
+
+	lines := strings.Split(string(src), "\n")
+
+	var b strings.Builder
+	b.WriteString("generated code does not parse:")
+	for i, e := range list {
+		if i == maxSourceErrors {
+			fmt.Fprintf(&b, "\n%v more errors", len(list)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\nline %v: %v", e.Pos.Line, e.Msg)
+		if e.Pos.Line >= 1 && e.Pos.Line <= len(lines) {
+			fmt.Fprintf(&b, "\n\t%v", strings.TrimSpace(lines[e.Pos.Line-1]))
+		}
+	}
+
+	return errors.New(b.String())
+}
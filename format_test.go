@@ -0,0 +1,69 @@
+package lexmlparser
+
+import (
+	"go/format"
+	"strings"
+	"testing"
+)
+
+func TestGenerateFormatted(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		t.Fatalf("format.Source: %v", err)
+	}
+	if string(formatted) != code {
+		t.Errorf("generated code is not formatted like gofmt")
+	}
+}
+
+func TestFormatSourceUnusedImports(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	_ "unsafe"
+)
+
+var s = fmt.Sprint(math.Pi)
+`
+
+	b, err := formatSource([]byte(src))
+	if err != nil {
+		t.Fatalf("formatSource: %v", err)
+	}
+	code := string(b)
+	checkContains(t, code, `"fmt"`, `"math"`, `_ "unsafe"`)
+	if strings.Contains(code, `"strings"`) {
+		t.Errorf("the unused import of strings was not removed:\n%v", code)
+	}
+
+	b, err = formatSource([]byte("package main\n\nimport \"strings\"\n\nvar s = 1\n"))
+	if err != nil {
+		t.Fatalf("formatSource: %v", err)
+	}
+	if strings.Contains(string(b), "import") {
+		t.Errorf("the import declaration was not removed:\n%s", b)
+	}
+}
+
+func TestFormatSourceError(t *testing.T) {
+	src := `package main
+
+func (a Foo) Decode(b []byte) (Arguments, error) {
+arg := FooArguments{
+return arg, nil
+}
+`
+
+	_, err := formatSource([]byte(src))
+	if err == nil {
+		t.Fatalf("got no error for code that does not parse")
+	}
+	if !strings.Contains(err.Error(), "line 5: ") || !strings.Contains(err.Error(), "\n\treturn arg, nil") {
+		t.Errorf("got error %q, want the line number and the line which does not parse", err)
+	}
+}
@@ -0,0 +1,1076 @@
+package lexmlparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/postmannen/lexmlparser/model"
+)
+
+// generator will hold the state of the code generation.
+type generator struct {
+	// variablesForMap is a slice and are the collection of the command
+	// variables used to form a map value in the generated output (and not
+	// a map value in this code here).
+	// It will be used at the end of the code to create the key/values
+	// of the map structure in the output.
+	variablesForMap []string
+	// droneTypesToGoTypes is a map used to know how to map the types found in the xml like
+	// u8/i8/float etc to they're go equivalent.
+	droneTypesToGoTypes map[string]goType
+	// enums is the symbol table of the enums declared for a whole
+	// feature. The key is the feature name and the enum name separated
+	// by a dot.
+	enums map[string]*enumSymbol
+	// bitfields are the bitfield types already created, so each of them
+	// are only created once even if used by several arguments.
+	bitfields map[string]bool
+	// refs are the commands by the reference used for them in the
+	// comments of the xml, like 1-4-1 for the project, class and
+	// command ids, or 134-3 for the feature and message ids.
+	refs map[string]*model.Cmd
+	// proto is the protocol the code is created from, which is used to
+	// find the messages referred to by name in the comments.
+	proto *model.Protocol
+	// dangling are the references already warned about, so each of
+	// them are only warned about once.
+	dangling map[string]bool
+	// varNames are the names of the variables holding the Command value
+	// of the commands, decided before any code is created since the
+	// name of a variable depends on the other projects generated.
+	varNames map[*model.Cmd]string
+	// project is the project the code is created for when every project
+	// is created in its own package, and nil when all the projects are
+	// created in the same package.
+	project *model.Project
+	// packages are the import paths of the packages created for the
+	// projects when every project is created in its own package.
+	packages map[*model.Project]string
+	// multisettings are the members of the multisettings of the projects
+	// created, found by the links of the members. The multisettings with
+	// members not found in the input are not in the map.
+	multisettings map[*model.Multisetting][]*model.Cmd
+	// imports are the packages used by the code created so far. The
+	// import declaration is printed after the rest of the code is
+	// created, so only the packages used are imported.
+	imports map[string]bool
+	// commands are all the commands created, which get a method in the
+	// Dispatcher type and an entry in the Commands created at the end of
+	// the code.
+	commands []CmdData
+	// states are the commands which are a state of the drone, which
+	// get a method in the State type created at the end of the code.
+	states []CmdData
+	// templates are the templates used to create the code, with the
+	// functions bound to this generator.
+	templates *template.Template
+	// output is where to redirect the output of the printing.
+	output io.Writer
+}
+
+type goType struct {
+	name   string
+	length string
+}
+
+// enumSymbol is an enum declared for a whole feature.
+type enumSymbol struct {
+	project *model.Project
+	enum    *model.Enum
+	// builtin is true if the enum was not found in the xml, but was
+	// created from a definition in the generator. The type for it is
+	// created the first time it is used.
+	builtin bool
+}
+
+// typeName will return the name of the Go type created for the enum.
+func (e *enumSymbol) typeName() string {
+	return sharedEnumTypeName(e.project, e.enum)
+}
+
+// listFlagsEnum is the list_flags enum declared in generic.xml. It is
+// used by the MAP_ITEM and LIST_ITEM events of most features, and is
+// created from this definition when generic.xml is not part of the input.
+var listFlagsEnum = &model.Enum{
+	Name:    "list_flags",
+	Comment: "Flags use by maps and lists",
+	Values: []*model.Value{
+		{Name: "First", Comment: "indicate it's the first element of the list.", Value: 0},
+		{Name: "Last", Comment: "indicate it's the last element of the list.", Value: 1},
+		{Name: "Empty", Comment: "indicate the list is empty (implies First/Last). All other arguments should be ignored.", Value: 2},
+		{Name: "Remove", Comment: "This value should be removed from the existing list.", Value: 3},
+	},
+}
+
+/*
+u8 1 unsigned 8bit value
+i8 1 signed 8bit value
+u16 2 unsigned 16bit value
+i16 2 signed 16bit value
+u32 4 unsigned 32bit value
+i32 4 signed 32bit value
+u64 8 unsigned 64bit value
+i64 8 signed 64bit value
+float 4 IEEE-754 single precision
+double 8 IEEE-754 double precision
+string * Null terminated string (C-String)
+(Variable size)
+enum 4 Per command defined enum
+*/
+
+// newGenerator will return a new *generator struct that will hold the state of the
+// code generation.
+func newGenerator(w io.Writer, opts Options) (*generator, error) {
+	g := &generator{
+		variablesForMap: []string{},
+		droneTypesToGoTypes: map[string]goType{
+			"u8":     goType{name: "uint8", length: "1"},
+			"i8":     goType{name: "int8", length: "1"},
+			"u16":    goType{name: "uint16", length: "2"},
+			"i16":    goType{name: "int16", length: "2"},
+			"u32":    goType{name: "uint32", length: "4"},
+			"i32":    goType{name: "int32", length: "4"},
+			"u64":    goType{name: "uint64", length: "8"},
+			"i64":    goType{name: "int64", length: "8"},
+			"float":  goType{name: "float32", length: "4"},
+			"double": goType{name: "float64", length: "8"},
+			"string": goType{name: "string", length: "0"},
+			"enum":   goType{name: "uint32", length: "4"},
+		},
+		enums:     map[string]*enumSymbol{},
+		bitfields: map[string]bool{},
+		refs:      map[string]*model.Cmd{},
+		dangling:  map[string]bool{},
+		varNames:  map[*model.Cmd]string{},
+		packages:  map[*model.Project]string{},
+
+		multisettings: map[*model.Multisetting][]*model.Cmd{},
+		imports:       map[string]bool{},
+		output:        w,
+	}
+
+	t := opts.Templates
+	if t == nil {
+		t = defaultTemplates
+	}
+	// The templates are cloned so the functions can be bound to the
+	// imports of this generator.
+	t, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	g.templates = t.Funcs(templateFuncs(g.imports))
+
+	return g, nil
+}
+
+// Options are the settings for the code generation.
+type Options struct {
+	// Package is the name of the package created by Generate. The
+	// package is named main if it is not given.
+	Package string
+	// BuildTags are the build tags all required for the code created
+	// to be built.
+	BuildTags []string
+	// Sources are the names of the xml files the code was created from,
+	// listed in the header of the code.
+	Sources []string
+	// Checksum is the checksum of the xml files the code was created
+	// from, as returned by Checksum. It is written in the header of the
+	// code, so it can be checked if the code is older than the xml.
+	Checksum string
+	// Templates are the templates used to create the code, as returned
+	// by LoadTemplates. The default templates are used if nil.
+	Templates *template.Template
+}
+
+// Generate will write the Go code for all the projects in the
+// protocol model to w.
+func Generate(w io.Writer, proto *model.Protocol, opts Options) error {
+	g, err := newGenerator(nil, opts)
+	if err != nil {
+		return err
+	}
+	g.buildTables(proto, proto.Projects)
+
+	pkgName := opts.Package
+	if pkgName == "" {
+		pkgName = "main"
+	}
+
+	return g.generate(w, pkgName, proto.Projects, opts)
+}
+
+// buildTables will build the tables needed before any code is created.
+// The enums and references are looked up in all the projects of the
+// protocol, and the variable names are decided for the projects
+// created in the same package.
+func (g *generator) buildTables(proto *model.Protocol, projects []*model.Project) {
+	g.proto = proto
+
+	// Build the symbol table of the enums declared for the features before
+	// any code is created, since the arguments can refer to enums declared
+	// in other features.
+	for _, project := range proto.Projects {
+		for _, enum := range project.Enums {
+			g.enums[project.Name+"."+enum.Name] = &enumSymbol{project: project, enum: enum}
+		}
+	}
+	// Build the table of the references used in the comments, so a
+	// comment can refer to commands in other projects.
+	for _, project := range proto.Projects {
+		for _, cmd := range project.Msgs {
+			g.refs[fmt.Sprintf("%v-%v", project.ID, cmd.ID)] = cmd
+		}
+		for _, class := range project.Classes {
+			for _, cmd := range class.Cmds {
+				g.refs[fmt.Sprintf("%v-%v-%v", project.ID, class.ID, cmd.ID)] = cmd
+			}
+		}
+	}
+
+	// The variables of the commands in a project are named after the
+	// class and the command, which are not unique when several projects
+	// are generated together. Those used by more than one command are
+	// named as the type with Cmd added to the end instead.
+	used := map[string]int{}
+	for _, project := range projects {
+		for _, class := range project.Classes {
+			for _, cmd := range class.Cmds {
+				used[cmdVarName(cmd)]++
+			}
+		}
+	}
+	for _, project := range projects {
+		for _, class := range project.Classes {
+			for _, cmd := range class.Cmds {
+				if used[cmdVarName(cmd)] > 1 {
+					g.varNames[cmd] = cmdTypeName(cmd) + "Cmd"
+				}
+			}
+		}
+	}
+
+	// Find the commands of the members of the multisettings, which are
+	// normally declared in other projects.
+	for _, project := range projects {
+		for _, ms := range project.Multisettings {
+			var cmds []*model.Cmd
+			for _, m := range ms.Members {
+				cmd := proto.Link(m.Link)
+				if cmd == nil {
+					log.Printf("warning: multisetting %v.%v: member %v not found, the multisetting is kept as bytes\n", project.Name, ms.Name, m.Link)
+					cmds = nil
+					break
+				}
+				cmds = append(cmds, cmd)
+			}
+			if cmds != nil {
+				g.multisettings[ms] = cmds
+			}
+		}
+	}
+
+	if _, ok := g.enums["generic.list_flags"]; !ok {
+		g.enums["generic.list_flags"] = &enumSymbol{
+			project: &model.Project{Name: "generic", Feature: true},
+			enum:    listFlagsEnum,
+			builtin: true,
+		}
+	}
+}
+
+// generate will write the Go code for the projects to w, as a package
+// with the given name.
+func (g *generator) generate(w io.Writer, pkgName string, projects []*model.Project, opts Options) error {
+	// The code is created into a buffer first, since the imports are
+	// not known before all the code is created.
+	var body bytes.Buffer
+	g.output = &body
+
+	for _, project := range projects {
+		err := g.doProject(project)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := g.printMapDeclaration(); err != nil {
+		return err
+	}
+	if err := g.execute("state", g.states); err != nil {
+		return err
+	}
+	if err := g.execute("dispatcher", g.commands); err != nil {
+		return err
+	}
+	if err := g.execute("commands", g.commands); err != nil {
+		return err
+	}
+
+	// The whole file is created before it is formatted, since the code
+	// can only be parsed as a whole.
+	var src bytes.Buffer
+	g.output = &src
+
+	printHeader(g.output, opts)
+	fmt.Fprintf(g.output, "package %v\n", pkgName)
+	fmt.Fprintln(g.output)
+
+	g.printTopDeclarations()
+
+	body.WriteTo(&src)
+
+	return writeSource(w, src.Bytes())
+}
+
+// writeSource will format the code, and write it to w.
+func writeSource(w io.Writer, src []byte) error {
+	b, err := formatSource(src)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// doProject will create the code for a project, and all the classes
+// and commands within it. A feature have no classes, and the code for
+// its commands and events are created directly.
+func (g *generator) doProject(project *model.Project) error {
+	if project.Comment != "" {
+		fmt.Fprintf(g.output, "// %v\n", g.docText(project.Comment))
+	}
+	fmt.Fprintf(g.output, "const %v ProjectDef = %v\n", projectConstName(project), project.ID)
+
+	// The enums of a feature are shared by all its messages, so the types
+	// for them are created before the messages that use them.
+	for _, enum := range project.Enums {
+		err := g.printEnum(sharedEnumTypeName(project, enum), g.droneTypesToGoTypes["enum"].name, enum, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The multisettings are created before the messages that use them.
+	for _, ms := range project.Multisettings {
+		if err := g.printMultisetting(ms); err != nil {
+			return fmt.Errorf("feature %v: multisetting %v: %v", project.Name, ms.Name, err)
+		}
+	}
+
+	for _, class := range project.Classes {
+		err := g.doClass(class)
+		if err != nil {
+			return fmt.Errorf("project %v: %v", project.Name, err)
+		}
+	}
+
+	for _, msg := range project.Msgs {
+		err := g.doCommand(msg)
+		if err != nil {
+			return fmt.Errorf("feature %v: %v", project.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// doClass will create the code for a class, and all the commands
+// within it.
+func (g *generator) doClass(class *model.Class) error {
+	if class.Comment != "" {
+		fmt.Fprintf(g.output, "// %v\n", g.docText(class.Comment))
+	}
+	fmt.Fprintf(g.output, "const %v ClassDef = %v\n", classConstName(class), class.ID)
+
+	for _, cmd := range class.Cmds {
+		err := g.doCommand(cmd)
+		if err != nil {
+			return fmt.Errorf("class %v: %v", class.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// doCommand will create the code for a command.
+func (g *generator) doCommand(cmd *model.Cmd) error {
+	// We need a buffer of all the arguments with their Go types, since the
+	// use of the arguments will be mixed with the cmd in the generated output text.
+	argBuf, err := g.newArgBufferForCmd(cmd)
+	if err != nil {
+		return fmt.Errorf("%v: %v", cmd.Name, err)
+	}
+
+	// ----------------------------------------------------------------------------------
+	// -------------------------CREATE COMMENTS------------------------------------------
+
+	// Create the comments above the const declaration.
+	for _, v := range []struct{ name, text string }{
+		{"title", cmd.Comment.Title},
+		{"desc", cmd.Comment.Desc},
+		{"support", cmd.Comment.Support},
+		{"result", cmd.Comment.Result},
+		{"triggered", cmd.Comment.Triggered},
+	} {
+		if v.text != "" {
+			fmt.Fprintf(g.output, "// %v : %v, \n", v.name, g.docText(v.text))
+		}
+	}
+	if cmd.Deprecated && cmd.Comment != (model.Comment{}) {
+		fmt.Fprintln(g.output, "//")
+	}
+	g.printDeprecated(cmd)
+
+	// ---------------------------------------------------------------------------------------
+	// -------------------------CREATE CONST AND TYPES----------------------------------------
+	fmt.Fprintf(g.output, "const %v CmdDef = %v\n", cmdConstName(cmd), cmd.ID)
+	fmt.Fprintln(g.output)
+
+	// Create the struct type command which will hold the decode methods
+	// for the command
+	g.printDeprecated(cmd)
+	fmt.Fprintf(g.output, "type %v Command\n", cmdTypeName(cmd))
+	fmt.Fprintln(g.output)
+
+	// Create the named types for the enums of the arguments, so the
+	// arguments struct can use them.
+	for _, v := range argBuf {
+		if v.Enum != nil {
+			if err := g.printEnum(v.GoType, v.BaseType, v.Enum, cmd); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Create a specific struct for a specific command, by adding Arguments to the end of the
+	// command name, together with the methods to decode and encode the
+	// arguments.
+	data := CmdData{
+		Cmd:          cmd,
+		TypeName:     cmdTypeName(cmd),
+		VarName:      g.varName(cmd),
+		ProjectConst: projectConstName(cmd.Project),
+		// The messages of a feature have no class, and are sent with a
+		// class id of 0.
+		ClassConst: "0",
+		CmdConst:   cmdConstName(cmd),
+		Args:       argBuf,
+	}
+	if cmd.Class != nil {
+		data.ClassConst = classConstName(cmd.Class)
+	}
+	if arg := cmd.ListFlags(); arg != nil {
+		data.ListFlags = argFieldName(arg)
+	}
+
+	// Create the methods telling what buffer the command should be sent
+	// on and what to do if it was not acknowledged in time, together with
+	// the method returning the messages expected after the command was
+	// sent, for the commands having any.
+	for _, name := range []string{"struct", "decoder", "encoder", "buffer", "expectations"} {
+		if err := g.execute(name, data); err != nil {
+			return err
+		}
+	}
+
+	// Create the methods used to assemble the items of a list, for the
+	// commands which are an item of a list or a map, and the methods
+	// added by the user templates, if any.
+	for _, name := range []string{"listitem", "methods"} {
+		if err := g.execute(name, data); err != nil {
+			return err
+		}
+	}
+
+	// -------------------------------------------------------------------------------------------
+	// ----------------------------CREATE VAR BASED ON TYPE---------------------------------------
+
+	if err := g.execute("var", data); err != nil {
+		return err
+	}
+
+	// store the variable name in a slice so we can use it
+	// to create the map[command]decoder map later.
+	g.variablesForMap = append(g.variablesForMap, g.varName(cmd))
+	g.commands = append(g.commands, data)
+	if cmd.IsState() {
+		g.states = append(g.states, data)
+	}
+
+	return nil
+}
+
+// newArgBufferForCmd will create a buffer of all the arguments of a cmd,
+// with the Go type to use for each of them.
+func (g *generator) newArgBufferForCmd(cmd *model.Cmd) ([]ArgData, error) {
+	var argBuffer []ArgData
+
+	for _, v := range cmd.Args {
+		typ, err := g.argGoType(v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %v: %v", v.Name, err)
+		}
+
+		// The field is tagged with the name of the argument in the xml,
+		// which is the name used when referring to the argument in the
+		// expectations of the commands.
+		a := ArgData{
+			Name:     argFieldName(v),
+			XMLName:  v.Name,
+			XMLType:  v.Type,
+			GoType:   typ.name,
+			BaseType: typ.name,
+			Length:   typ.length,
+			Tag:      fmt.Sprintf("`arsdk:%q`", v.Name),
+		}
+		if v.Enum != nil {
+			a.GoType = enumTypeName(cmd, v)
+			a.Enum = v.Enum
+		}
+		if strings.HasPrefix(v.Type, "enum:") {
+			sym, err := g.resolveEnum(cmd.Project, strings.TrimPrefix(v.Type, "enum:"))
+			if err != nil {
+				return nil, fmt.Errorf("argument %v: %v", v.Name, err)
+			}
+			a.GoType = g.qualified(sym.project, sym.typeName())
+		}
+		if strings.HasPrefix(v.Type, "multisetting:") {
+			name := strings.TrimPrefix(v.Type, "multisetting:")
+			ms := cmd.Project.Multisetting(name)
+			if ms == nil {
+				return nil, fmt.Errorf("argument %v: multisetting %q is not declared in the multisettings of %v", v.Name, name, cmd.Project.Name)
+			}
+			// The argument is kept as []byte if the commands of the
+			// members were not found in the input.
+			if _, ok := g.multisettings[ms]; ok {
+				a.GoType = multisettingTypeName(ms)
+				a.BaseType = a.GoType
+				a.Multisetting = ms
+			}
+		}
+		if strings.HasPrefix(v.Type, "bitfield:") {
+			// A bitfield is given as bitfield:<type>:<enum name>.
+			parts := strings.Split(v.Type, ":")
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("argument %v: bad bitfield type %q", v.Name, v.Type)
+			}
+			sym, err := g.resolveEnum(cmd.Project, parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("argument %v: %v", v.Name, err)
+			}
+			a.GoType = bitfieldTypeName(sym.project, sym.enum)
+			if !g.bitfields[a.GoType] {
+				if err := g.printBitfield(a.GoType, a.BaseType, a.Length, sym); err != nil {
+					return nil, err
+				}
+				g.bitfields[a.GoType] = true
+			}
+		}
+
+		argBuffer = append(argBuffer, a)
+	}
+
+	return argBuffer, nil
+}
+
+// resolveEnum will return the symbol of an enum declared for a whole
+// feature. The enum is first looked up in the feature itself, and then
+// in the generic feature which holds the enums used by all features.
+func (g *generator) resolveEnum(project *model.Project, name string) (*enumSymbol, error) {
+	sym, ok := g.enums[project.Name+"."+name]
+	if !ok {
+		sym, ok = g.enums["generic."+name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("enum %q is not declared in the enums of %v", name, project.Name)
+	}
+
+	// The builtin enums are created the first time they are used.
+	if sym.builtin {
+		err := g.printEnum(sym.typeName(), g.droneTypesToGoTypes["enum"].name, sym.enum, nil)
+		if err != nil {
+			return nil, err
+		}
+		sym.builtin = false
+	}
+
+	return sym, nil
+}
+
+// argGoType will lookup, and pick the needed values from the type
+// specification map for the xml type of an argument.
+func (g *generator) argGoType(arg *model.Arg) (goType, error) {
+	typ := arg.Type
+
+	switch {
+	case strings.HasPrefix(typ, "enum:"):
+		// The enums of a feature are declared once for the whole feature,
+		// and referenced by name, but are sent like any other enum.
+		typ = "enum"
+	case strings.HasPrefix(typ, "bitfield:"):
+		// A bitfield is given as bitfield:<type>:<enum name>, and are sent
+		// as the type given in the middle.
+		typ = strings.Split(typ, ":")[1]
+	case strings.HasPrefix(typ, "multisetting:"):
+		// The payload is kept as it is, unless the commands of the
+		// multisetting are found in the input.
+		return goType{name: "[]byte", length: "*"}, nil
+	}
+
+	v, ok := g.droneTypesToGoTypes[typ]
+	if !ok {
+		return goType{}, fmt.Errorf("unknown type %q", arg.Type)
+	}
+
+	return v, nil
+}
+
+// appendLittleEndian will return the code to append the integer value
+// of expr with the given length in bytes to b, in little endian order.
+func appendLittleEndian(expr string, length string) string {
+	n, _ := strconv.Atoi(length)
+
+	var bytes []string
+	for i := 0; i < n; i++ {
+		if i == 0 {
+			bytes = append(bytes, "byte("+expr+")")
+			continue
+		}
+		bytes = append(bytes, fmt.Sprintf("byte(%v>>%v)", expr, i*8))
+	}
+
+	return "b = append(b, " + strings.Join(bytes, ", ") + ")"
+}
+
+// docRefRegexp matches the references to other commands in the
+// comments of the xml, which are written like [FlyingState](#1-4-1), or
+// only like (#134-3). The messages of a feature can also be referred to
+// by name, like [WifiCountryChanged](#wifi-CountryChanged).
+var docRefRegexp = regexp.MustCompile(`(\[[^\]]*\]\s*)?\(#([^()\s]+)\)`)
+
+// docText will replace the references to other commands in a comment
+// with Go doc links to the types created for the commands. The
+// references to commands not found in the input are left as they are,
+// and a warning is logged.
+func (g *generator) docText(s string) string {
+	return docRefRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		sub := docRefRegexp.FindStringSubmatch(m)
+		cmd := g.resolveRef(sub[2])
+		if cmd == nil {
+			if !g.dangling[sub[2]] {
+				g.dangling[sub[2]] = true
+				log.Printf("warning: dangling reference #%v in comment\n", sub[2])
+			}
+			return m
+		}
+
+		link := "[" + cmdTypeName(cmd) + "]"
+		// The links to other packages are written with the import path,
+		// so the package do not have to be imported.
+		if path, ok := g.packages[cmd.Project]; ok && cmd.Project != g.project {
+			link = "[" + path + "." + cmdTypeName(cmd) + "]"
+		}
+		if sub[1] == "" {
+			return "(" + link + ")"
+		}
+		return link
+	})
+}
+
+// resolveRef will return the command referred to by a reference in a
+// comment, which is either the ids like 1-4-1 or 134-3, or the name of a
+// feature and a message like wifi-country_changed. The xml also writes
+// the names of the messages like wifi-CountryChanged, so they are
+// compared without case and underscores if no message has the exact
+// name. Nil is returned if no such command is found.
+func (g *generator) resolveRef(ref string) *model.Cmd {
+	if cmd, ok := g.refs[ref]; ok {
+		return cmd
+	}
+
+	i := strings.Index(ref, "-")
+	if i == -1 || g.proto == nil {
+		return nil
+	}
+	feature, name := ref[:i], ref[i+1:]
+	if cmd := g.proto.Link(feature + "." + name); cmd != nil {
+		return cmd
+	}
+
+	fold := func(s string) string {
+		return strings.ToLower(strings.Replace(s, "_", "", -1))
+	}
+	for _, project := range g.proto.Projects {
+		if project.Name != feature {
+			continue
+		}
+		for _, cmd := range project.Msgs {
+			if fold(cmd.Name) == fold(name) {
+				return cmd
+			}
+		}
+	}
+
+	return nil
+}
+
+// printMultisetting will print the struct type for a multisetting, with
+// a field for the arguments of every member. Nothing is printed if the
+// members of the multisetting were not found in the input.
+func (g *generator) printMultisetting(ms *model.Multisetting) error {
+	cmds, ok := g.multisettings[ms]
+	if !ok {
+		return nil
+	}
+
+	data := MultisettingData{
+		Multisetting: ms,
+		TypeName:     multisettingTypeName(ms),
+		Comment:      g.docText(ms.Comment),
+	}
+	for _, cmd := range cmds {
+		data.Members = append(data.Members, MemberData{
+			Cmd:      cmd,
+			Field:    cmdTypeName(cmd),
+			IsSet:    cmdTypeName(cmd) + "IsSet",
+			ArgsType: g.qualified(cmd.Project, cmdTypeName(cmd)+"Arguments"),
+			Var:      g.qualified(cmd.Project, g.varName(cmd)),
+		})
+	}
+
+	return g.execute("multisetting", data)
+}
+
+// qualified will return the name of an identifier declared for the
+// project, qualified with the package name when the project is created
+// in another package than the code being created.
+func (g *generator) qualified(project *model.Project, name string) string {
+	path, ok := g.packages[project]
+	if !ok || project == g.project {
+		return name
+	}
+	g.imports[path] = true
+	return packageName(project) + "." + name
+}
+
+// printDeprecated will print a deprecation notice for the identifiers
+// of a command marked as deprecated in the xml, so tools like staticcheck
+// can warn about their use.
+func (g *generator) printDeprecated(cmd *model.Cmd) {
+	fmt.Fprint(g.output, deprecatedNotice(cmd))
+}
+
+// deprecatedNotice will return the deprecation notice printed by
+// printDeprecated, or an empty string if the command is nil or not
+// deprecated.
+func deprecatedNotice(cmd *model.Cmd) string {
+	if cmd == nil || !cmd.Deprecated {
+		return ""
+	}
+	kind := "command"
+	if cmd.Event {
+		kind = "event"
+	}
+	return fmt.Sprintf("// Deprecated: the %v %v is deprecated in the xml.\n", kind, cmd.Name)
+}
+
+// execute will print the code created by the template with the given
+// name.
+func (g *generator) execute(name string, data interface{}) error {
+	err := g.templates.ExecuteTemplate(g.output, name, data)
+	if err != nil {
+		return fmt.Errorf("template %v: %v", name, err)
+	}
+	return nil
+}
+
+// printEnum will print a named type for an enum, with a constant for
+// each of the enum values and a String method returning the name of
+// the value as given in the xml. cmd is the command the enum is created
+// for, or nil for the enums declared for a whole feature.
+func (g *generator) printEnum(typeName string, baseType string, enum *model.Enum, cmd *model.Cmd) error {
+	data := EnumData{Enum: enum, Cmd: cmd, TypeName: typeName, BaseType: baseType}
+	if enum.Comment != "" {
+		data.Comment = g.docText(enum.Comment)
+	}
+	for _, v := range enum.Values {
+		value := ValueData{Value: v, Name: enumValueName(typeName, v)}
+		if v.Comment != "" {
+			value.Comment = g.docText(v.Comment)
+		}
+		data.Values = append(data.Values, value)
+	}
+
+	return g.execute("enum", data)
+}
+
+// printBitfield will print a named type for a bitfield, where each bit
+// tells if the enum value with the same number as the bit is set.
+// The type is sized as the type given in the xml.
+func (g *generator) printBitfield(typeName string, baseType string, length string, sym *enumSymbol) error {
+	bits, _ := strconv.Atoi(length)
+
+	return g.execute("bitfield", BitfieldData{
+		TypeName: typeName,
+		BaseType: baseType,
+		EnumType: g.qualified(sym.project, sym.typeName()),
+		Bits:     bits * 8,
+	})
+}
+
+// ---------------------------------------------------------------------------------------
+
+// printHeader will print the comment telling that the code is
+// generated, and the build constraints if any. The header is separated
+// from the package clause by an empty line, so it is not taken as the
+// documentation of the package.
+func printHeader(w io.Writer, opts Options) {
+	from := ""
+	if len(opts.Sources) != 0 {
+		from = " from " + strings.Join(opts.Sources, ", ")
+	}
+	fmt.Fprintf(w, "// Code generated by lexmlparser%v. DO NOT EDIT.\n", from)
+	if opts.Checksum != "" {
+		fmt.Fprintf(w, "// Source checksum: %v\n", opts.Checksum)
+	}
+	fmt.Fprintln(w)
+
+	if len(opts.BuildTags) != 0 {
+		fmt.Fprintf(w, "//go:build %v\n", strings.Join(opts.BuildTags, " && "))
+		fmt.Fprintf(w, "// +build %v\n", strings.Join(opts.BuildTags, ","))
+		fmt.Fprintln(w)
+	}
+}
+
+// printTopDeclarations will print things like package ...., func main,
+// imports, etc....
+// The types shared by all the generated code are declared in the arsdk
+// package, and the generated code refers to them by aliases.
+func (g *generator) printTopDeclarations() {
+	fmt.Fprintln(g.output, "import (")
+	for _, v := range []string{"fmt", "math"} {
+		if g.imports[v] {
+			fmt.Fprintf(g.output, "\t%q\n", v)
+		}
+	}
+	fmt.Fprintln(g.output)
+	fmt.Fprintln(g.output, `	"github.com/postmannen/lexmlparser/arsdk"`)
+	// The packages created for other projects, when every project is
+	// created in its own package.
+	var packages []string
+	for v := range g.imports {
+		if v != "fmt" && v != "math" {
+			packages = append(packages, v)
+		}
+	}
+	sort.Strings(packages)
+	for _, v := range packages {
+		fmt.Fprintf(g.output, "\t%q\n", v)
+	}
+	fmt.Fprintln(g.output, ")")
+	fmt.Fprintln(g.output)
+	fmt.Fprintln(g.output, "type (")
+	fmt.Fprintln(g.output, "	ProjectDef = arsdk.ProjectDef")
+	fmt.Fprintln(g.output, "	ClassDef   = arsdk.ClassDef")
+	fmt.Fprintln(g.output, "	CmdDef     = arsdk.CmdDef")
+	fmt.Fprintln(g.output, "	Command    = arsdk.Command")
+	fmt.Fprintln(g.output, "	Arguments  = arsdk.Arguments")
+	fmt.Fprintln(g.output, "	Decoder    = arsdk.Decoder")
+	fmt.Fprintln(g.output, "	Encoder    = arsdk.Encoder")
+	fmt.Fprintln(g.output, ")")
+	fmt.Fprintln(g.output)
+}
+
+// printMapDeclaration will print the whole map structure which
+// maps all the command variables to it's type.
+func (g *generator) printMapDeclaration() error {
+	return g.execute("commandmap", g.variablesForMap)
+}
+
+// bufferConstName will return the name of the arsdk constant for the
+// buffer class, which arnetworkal.BufferID maps to the id of the buffer
+// the command is sent on.
+func bufferConstName(b model.Buffer) string {
+	switch b {
+	case model.BufferNonAck:
+		return "arsdk.BufferNonAck"
+	case model.BufferHighPrio:
+		return "arsdk.BufferHighPrio"
+	}
+	return "arsdk.BufferAck"
+}
+
+// timeoutConstName will return the name of the arsdk constant for the
+// timeout policy.
+func timeoutConstName(t model.Timeout) string {
+	switch t {
+	case model.TimeoutRetry:
+		return "arsdk.TimeoutRetry"
+	case model.TimeoutFlush:
+		return "arsdk.TimeoutFlush"
+	}
+	return "arsdk.TimeoutPop"
+}
+
+func projectConstName(project *model.Project) string {
+	return "Project" + upperFirstCharacter(project.Name)
+}
+
+// classConstName will return the name of the const holding the
+// id of the class.
+func classConstName(class *model.Class) string {
+	return upperFirstCharacter(class.Project.Name) + upperFirstCharacter(class.Name) + "Class" + upperFirstCharacter(class.Name)
+}
+
+// cmdConstName will return the name of the const holding the
+// id of the command.
+func cmdConstName(cmd *model.Cmd) string {
+	if cmd.Class == nil {
+		return upperFirstCharacter(cmd.Project.Name) + msgKind(cmd) + camelCase(cmd.Name)
+	}
+	return upperFirstCharacter(cmd.Project.Name) + upperFirstCharacter(cmd.Class.Name) + "Cmd" + upperFirstCharacter(cmd.Name)
+}
+
+// cmdTypeName will return the name of the type created for the
+// command, which is the project, class and command names concatenated.
+// The messages of a feature uses the feature and message names.
+func cmdTypeName(cmd *model.Cmd) string {
+	if cmd.Class == nil {
+		return upperFirstCharacter(cmd.Project.Name) + camelCase(cmd.Name)
+	}
+	return upperFirstCharacter(concatenateSlice([]string{cmd.Project.Name, cmd.Class.Name, cmd.Name}))
+}
+
+// cmdVarName will return the name of the variable holding the
+// Command value of the command.
+// The message names of features are only unique within the feature,
+// so the variables for them are named as the type with Cmd or Evt
+// added to the end.
+func cmdVarName(cmd *model.Cmd) string {
+	if cmd.Class == nil {
+		return cmdTypeName(cmd) + msgKind(cmd)
+	}
+	return upperFirstCharacter(cmd.Class.Name + cmd.Name)
+}
+
+// varName will return the name of the variable holding the Command
+// value of the command, which is the one from cmdVarName unless it is
+// used by several commands generated together.
+func (g *generator) varName(cmd *model.Cmd) string {
+	if v, ok := g.varNames[cmd]; ok {
+		return v
+	}
+	return cmdVarName(cmd)
+}
+
+// msgKind will return Evt for the events of a feature, and Cmd
+// for everything else.
+func msgKind(cmd *model.Cmd) string {
+	if cmd.Event {
+		return "Evt"
+	}
+	return "Cmd"
+}
+
+// enumTypeName will return the name of the type created for the
+// enum of an argument, which is the command type with the argument
+// name added to the end.
+func enumTypeName(cmd *model.Cmd, arg *model.Arg) string {
+	return cmdTypeName(cmd) + argFieldName(arg)
+}
+
+// sharedEnumTypeName will return the name of the type created for an
+// enum declared for a whole feature. Enum added to the end of the name
+// so it will not collide with the types created for the messages.
+func sharedEnumTypeName(project *model.Project, enum *model.Enum) string {
+	return upperFirstCharacter(project.Name) + camelCase(enum.Name) + "Enum"
+}
+
+// bitfieldTypeName will return the name of the type created for a
+// bitfield of the values of an enum declared for a whole feature.
+func bitfieldTypeName(project *model.Project, enum *model.Enum) string {
+	return upperFirstCharacter(project.Name) + camelCase(enum.Name) + "Bitfield"
+}
+
+// multisettingTypeName will return the name of the type created for a
+// multisetting.
+func multisettingTypeName(ms *model.Multisetting) string {
+	return upperFirstCharacter(ms.Project.Name) + camelCase(ms.Name) + "Multisetting"
+}
+
+// enumValueName will return the name of the const for an enum value.
+func enumValueName(typeName string, value *model.Value) string {
+	return typeName + camelCase(value.Name)
+}
+
+// argFieldName will return the name of the struct field for an argument.
+func argFieldName(arg *model.Arg) string {
+	name := arg.Name
+	// check if the name is == type, and add an X to not conflict with go's
+	// type system.
+	if name == "type" {
+		name += "X"
+	}
+
+	// check if the name contains underscores, and if it does, remove them.
+	name = concatenateSlice(strings.Split(name, "_"))
+
+	return upperFirstCharacter(name)
+}
+
+// camelCase will turn a snake_case name like set_mode into SetMode.
+// The underscore is kept between two digits, so 2_4ghz becomes 2_4ghz.
+func camelCase(s string) string {
+	var output string
+	for _, v := range strings.Split(s, "_") {
+		if output != "" && v != "" && unicode.IsDigit(rune(output[len(output)-1])) && unicode.IsDigit(rune(v[0])) {
+			output += "_"
+		}
+		output += upperFirstCharacter(v)
+	}
+
+	return output
+}
+
+// lowerFirstCharacer, turns the first character of a string
+// to lowercase.
+func lowerFirstCharacter(s string) string {
+	if s == "" {
+		return ""
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+// upperFirstCharacer, turns the first character of a string
+// to uppercase.
+func upperFirstCharacter(s string) string {
+	if s == "" {
+		return ""
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+// concatenateSlice will take all the string elements of
+// a slice, and return them as a single string.
+func concatenateSlice(s []string) string {
+	var output string
+	for _, v := range s {
+		output += v
+	}
+
+	return output
+}
@@ -0,0 +1,537 @@
+package lexmlparser
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generateString will parse the xml given as a string, and return the
+// generated code.
+func generateString(t *testing.T, xml string) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	err := Generate(&buf, parseString(t, xml), Options{})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	return buf.String()
+}
+
+// checkContains will check that all the wanted lines are found in the
+// generated code. The indentation and the spaces used to align the code
+// are ignored.
+func checkContains(t *testing.T, code string, want ...string) {
+	t.Helper()
+
+	code = collapseSpace(code)
+	for _, w := range want {
+		if !strings.Contains(code, collapseSpace(w)) {
+			t.Errorf("generated code is missing %q", w)
+		}
+	}
+}
+
+// collapseSpace will remove the indentation of the lines, and replace
+// the other runs of spaces and tabs with a single space.
+func collapseSpace(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.Join(strings.Fields(l), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestGenerateEnum(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"type Ardrone3PilotingStateFlyingStateChangedState uint32\n",
+		"Ardrone3PilotingStateFlyingStateChangedStateLanded Ardrone3PilotingStateFlyingStateChangedState = 0\n",
+		"Ardrone3PilotingStateFlyingStateChangedStateTakingoff Ardrone3PilotingStateFlyingStateChangedState = 1\n",
+		"func (e Ardrone3PilotingStateFlyingStateChangedState) String() string {\n",
+		"return \"takingoff\"\n",
+		"State Ardrone3PilotingStateFlyingStateChangedState `arsdk:\"state\"`\n",
+	)
+}
+
+func TestGenerateSharedEnum(t *testing.T) {
+	code := generateString(t, testFeatureXML)
+
+	checkContains(t, code,
+		"type WifiBandEnum uint32\n",
+		"WifiBandEnum2_4ghz WifiBandEnum = 0\n",
+		"WifiBandEnum5ghz WifiBandEnum = 1\n",
+		"Band WifiBandEnum `arsdk:\"band\"`\n",
+	)
+
+	xml := strings.Replace(testFeatureXML, "enum:band", "enum:channel", 1)
+	err := Generate(&bytes.Buffer{}, parseString(t, xml), Options{})
+	if err == nil || !strings.Contains(err.Error(), `enum "channel" is not declared`) {
+		t.Errorf("got error %v, want an error about the unresolved enum", err)
+	}
+}
+
+func TestGenerateBitfield(t *testing.T) {
+	code := generateString(t, testFeatureXML)
+
+	checkContains(t, code,
+		"type WifiBandBitfield uint8\n",
+		"func (b WifiBandBitfield) Has(v WifiBandEnum) bool {\n",
+		"func (b *WifiBandBitfield) Set(v WifiBandEnum) {\n",
+		"func (b *WifiBandBitfield) Clear(v WifiBandEnum) {\n",
+		"func (b WifiBandBitfield) Values() []WifiBandEnum {\n",
+		"func (b WifiBandBitfield) String() string {\n",
+		"Band WifiBandBitfield `arsdk:\"band\"`\n",
+		"if err := arsdk.ConvLittleEndianSliceToNumeric(b[offset:offset+1], (*uint8)(&arg.Band)); err != nil {\n"+
+			`return nil, &arsdk.DecodeError{Cmd: "WifiScan", Arg: "Band", Offset: offset, Err: err}`,
+		// list_flags is declared in generic.xml, and is created from the
+		// builtin definition since generic.xml is not part of the input.
+		"type GenericListFlagsEnum uint32\n",
+		"type GenericListFlagsBitfield uint8\n",
+		"Listflags GenericListFlagsBitfield `arsdk:\"list_flags\"`\n",
+	)
+
+	if n := strings.Count(code, "type GenericListFlagsBitfield "); n != 1 {
+		t.Errorf("got %v GenericListFlagsBitfield types, want 1", n)
+	}
+
+	for _, typ := range []string{"bitfield:u8", "bitfield:u8:band:band"} {
+		xml := strings.Replace(testFeatureXML, "bitfield:u8:band", typ, 1)
+		err := Generate(&bytes.Buffer{}, parseString(t, xml), Options{})
+		want := fmt.Sprintf("argument band: bad bitfield type %q", typ)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %v for type %v, want %v", err, typ, want)
+		}
+	}
+}
+
+func TestGenerateEncode(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"func (a Ardrone3PilotingPCMDArguments) Encode(b []byte) []byte {\n",
+		"b = append(b, byte(a.Roll))\n",
+		"b = append(b, byte(a.State), byte(a.State>>8), byte(a.State>>16), byte(a.State>>24))\n",
+	)
+	if strings.Contains(code, "reflect") {
+		t.Errorf("generated code should not use the reflect package")
+	}
+
+	code = generateString(t, testFeatureXML)
+	checkContains(t, code,
+		"b = append(b, a.Ssid...)\nb = append(b, 0)\n",
+	)
+}
+
+func TestGenerateDecode(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"func (a Ardrone3PilotingPCMD) Decode(b []byte) (Arguments, error) {\n",
+		"if len(b) < offset+1 {\n",
+		`return nil, &arsdk.DecodeError{Cmd: "Ardrone3PilotingPCMD", Arg: "Roll", Offset: offset, Err: arsdk.ErrShortPayload}`,
+		"return arg, nil\n",
+		"Decoder    = arsdk.Decoder\n",
+	)
+
+	code = generateString(t, testFeatureXML)
+	checkContains(t, code,
+		`return nil, &arsdk.DecodeError{Cmd: "WifiScannedItem", Arg: "Ssid", Offset: offset, Err: err}`,
+	)
+	if strings.Contains(code, "log.Println") {
+		t.Errorf("generated decode methods should return errors instead of logging them")
+	}
+}
+
+// roundTripMain is the program checking that the generated Decode
+// methods decode the arguments encoded by the generated Encode methods,
+// for arguments filled with random values.
+const roundTripMain = `package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+
+	"github.com/postmannen/lexmlparser/arsdk"
+	"roundtrip/gen/ardrone3"
+	"roundtrip/gen/wifi"
+)
+
+func fill(r *rand.Rand, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(r.Uint64())
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(r.Uint64()))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(r.NormFloat64())
+	case reflect.String:
+		b := make([]byte, r.Intn(16))
+		for i := range b {
+			b[i] = byte('a' + r.Intn(26))
+		}
+		v.SetString(string(b))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fill(r, v.Field(i))
+		}
+	}
+}
+
+func main() {
+	r := rand.New(rand.NewSource(1))
+	failed := false
+	for _, info := range append(ardrone3.Commands, wifi.Commands...) {
+		for i := 0; i < 10; i++ {
+			v := reflect.New(reflect.TypeOf(info.Args)).Elem()
+			fill(r, v)
+			want := v.Interface().(arsdk.Arguments)
+			b := want.Encode(nil)
+
+			got, err := arsdk.DecodeStrict(info.Decoder, b)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				fmt.Printf("%v: decoded %+v, %v from the encoded %+v\n", info.Name, got, err, want)
+				failed = true
+			}
+			_, err = arsdk.DecodeStrict(info.Decoder, append(b, 0))
+			if !errors.Is(err, arsdk.ErrTrailingData) {
+				fmt.Printf("%v: got error %v for a trailing byte\n", info.Name, err)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+`
+
+// TestGenerateRoundTrip will build the code generated for the test xml
+// in a module of its own, and run it to check that Decode is the inverse
+// of Encode for every command.
+func TestGenerateRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the build of the generated code in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not found")
+	}
+
+	root, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatalf("Abs: %v", err)
+	}
+	sum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	proto := parseString(t, testProjectXML)
+	proto.Projects = append(proto.Projects, parseString(t, testFeatureXML).Projects...)
+
+	dir := t.TempDir()
+	if err := GeneratePackages(filepath.Join(dir, "gen"), "roundtrip/gen", proto, Options{}); err != nil {
+		t.Fatalf("GeneratePackages: %v", err)
+	}
+	files := map[string]string{
+		"go.mod": "module roundtrip\n\ngo 1.15\n\n" +
+			"require github.com/postmannen/lexmlparser v0.0.0\n\n" +
+			"replace github.com/postmannen/lexmlparser => " + root + "\n",
+		"go.sum":  string(sum),
+		"main.go": roundTripMain,
+	}
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	cmd := exec.Command(goBin, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("go run: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateBuffer(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"func (a Ardrone3PilotingPCMD) Buffer() arsdk.Buffer {\nreturn arsdk.BufferNonAck\n",
+		"func (a Ardrone3PilotingEmergency) Buffer() arsdk.Buffer {\nreturn arsdk.BufferHighPrio\n",
+		"func (a Ardrone3PilotingStateFlyingStateChanged) Buffer() arsdk.Buffer {\nreturn arsdk.BufferAck\n",
+		"func (a Ardrone3PilotingEmergency) Timeout() arsdk.Timeout {\nreturn arsdk.TimeoutRetry\n",
+		"func (a Ardrone3PilotingPCMD) Timeout() arsdk.Timeout {\nreturn arsdk.TimeoutPop\n",
+	)
+}
+
+func TestGenerateListItem(t *testing.T) {
+	code := generateString(t, testFeatureXML)
+
+	checkContains(t, code,
+		"func (a WifiScannedItemArguments) ItemKind() arsdk.ListKind {\nreturn arsdk.MapItem\n",
+		"func (a WifiScannedItemArguments) ItemKey() interface{} {\nreturn a.Ssid\n",
+		"func (a WifiScannedItemArguments) ItemFlags() arsdk.ListFlags {\nreturn arsdk.ListFlags(a.Listflags)\n",
+	)
+	if strings.Contains(code, "func (a WifiScanArguments) ItemKind()") {
+		t.Errorf("ItemKind created for a command which is not a list item")
+	}
+
+	code = generateString(t, strings.Replace(testFeatureXML, `type="MAP_ITEM:ssid"`, `type="LIST_ITEM"`, 1))
+	checkContains(t, code,
+		"func (a WifiScannedItemArguments) ItemKind() arsdk.ListKind {\nreturn arsdk.ListItem\n",
+		"func (a WifiScannedItemArguments) ItemKey() interface{} {\na.Listflags = 0\nreturn a\n",
+	)
+}
+
+func TestGenerateState(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"var StateCommands = map[Command]bool{\nCommand(PilotingStateFlyingStateChanged): true,\n}",
+		"func NewState() State {\nreturn State{arsdk.NewState(StateCommands)}\n",
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\n"+
+			"func (s State) Ardrone3PilotingStateFlyingStateChanged() (Ardrone3PilotingStateFlyingStateChangedArguments, bool) {\n"+
+			"a, ok := s.Get(Command(PilotingStateFlyingStateChanged)).(Ardrone3PilotingStateFlyingStateChangedArguments)\n",
+	)
+	if strings.Contains(code, "func (s State) Ardrone3PilotingPCMD()") {
+		t.Errorf("State method created for a command which is not a state")
+	}
+
+	// The items of a list are assembled by an arsdk.Collector instead.
+	code = generateString(t, testFeatureXML)
+	checkContains(t, code, "var StateCommands = map[Command]bool{}")
+}
+
+func TestGenerateDispatcher(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"func NewDispatcher() Dispatcher {\nreturn Dispatcher{arsdk.NewDispatcher()}\n",
+		"func (d Dispatcher) OnArdrone3PilotingPCMD(fn func(Ardrone3PilotingPCMDArguments)) (unsubscribe func()) {\n"+
+			"return d.On(Command(PilotingPCMD), func(args Arguments) {\n"+
+			"if a, ok := args.(Ardrone3PilotingPCMDArguments); ok {\nfn(a)\n",
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\n"+
+			"func (d Dispatcher) OnArdrone3PilotingStateFlyingStateChanged(",
+	)
+}
+
+func TestGenerateCommands(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"var Commands = []arsdk.CommandInfo{\n"+
+			`{Command: Command(PilotingPCMD), Name: "ardrone3.Piloting.PCMD", Decoder: PilotingPCMD, Args: Ardrone3PilotingPCMDArguments{}},`,
+		`{Command: Command(PilotingStateFlyingStateChanged), Name: "ardrone3.PilotingState.FlyingStateChanged", `+
+			"Decoder: PilotingStateFlyingStateChanged, Args: Ardrone3PilotingStateFlyingStateChangedArguments{}, Event: true, Deprecated: true, State: true},",
+		"var Registry = arsdk.NewRegistry(Commands)",
+	)
+
+	// The commands of the classes named like PilotingEvent are events,
+	// but not states.
+	code = generateString(t, strings.Replace(testProjectXML, `name="PilotingState"`, `name="PilotingEvent"`, 1))
+	checkContains(t, code,
+		"Args: Ardrone3PilotingEventFlyingStateChangedArguments{}, Event: true, Deprecated: true},",
+	)
+
+	code = generateString(t, testFeatureXML)
+	checkContains(t, code,
+		`{Command: Command(WifiScannedItemEvt), Name: "wifi.scanned_item", Decoder: WifiScannedItemEvt, Args: WifiScannedItemArguments{}, Event: true},`,
+	)
+}
+
+func TestGenerateDeprecated(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\nconst Ardrone3PilotingStateCmdFlyingStateChanged CmdDef = 1\n",
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\ntype Ardrone3PilotingStateFlyingStateChanged Command\n",
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\ntype Ardrone3PilotingStateFlyingStateChangedArguments struct {\n",
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\nvar PilotingStateFlyingStateChanged = ",
+		// The enums created for the arguments are deprecated with the
+		// command.
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\ntype Ardrone3PilotingStateFlyingStateChangedState uint32\n",
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\nconst (\n// Landed state\nArdrone3PilotingStateFlyingStateChangedStateLanded ",
+		"// Deprecated: the command FlyingStateChanged is deprecated in the xml.\nfunc (e Ardrone3PilotingStateFlyingStateChangedState) String() string {\n",
+	)
+	// The methods of the State and Dispatcher types are also deprecated.
+	if n := strings.Count(code, "// Deprecated:"); n != 9 {
+		t.Errorf("got %v deprecation notices, want 9", n)
+	}
+
+	proto := parseString(t, testProjectXML)
+	proto.RemoveDeprecated()
+	var buf bytes.Buffer
+	if err := Generate(&buf, proto, Options{}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if strings.Contains(buf.String(), "FlyingStateChanged") {
+		t.Errorf("the deprecated command should not be generated")
+	}
+	checkContains(t, buf.String(), "var PilotingPCMD = ")
+}
+
+func TestGenerateExpectations(t *testing.T) {
+	code := generateString(t, testProjectXML)
+
+	checkContains(t, code,
+		"func (a Ardrone3PilotingEmergency) Expectations() []arsdk.Expectation {\n",
+		"{Delayed: false, OneOf: []arsdk.ExpectedMsg{\n",
+		`{Ref: "#1-4-1", Command: arsdk.Command{Project: 1, Class: 4, Cmd: 1}, Args: []arsdk.ArgPredicate{{Name: "state", Value: "landed"}, {Name: "reason", This: "cause"}}},`,
+		`{Ref: "#1-4-1", Command: arsdk.Command{Project: 1, Class: 4, Cmd: 1}, Args: []arsdk.ArgPredicate{{Name: "state", Value: "takingoff"}}},`,
+		"{Delayed: true, OneOf: []arsdk.ExpectedMsg{\n{Ref: \"#135-2\", Command: arsdk.Command{Project: 135, Class: 0, Cmd: 2}},\n",
+	)
+	if strings.Contains(code, "func (a Ardrone3PilotingPCMD) Expectations()") {
+		t.Errorf("commands without expectations should not get an Expectations method")
+	}
+}
+
+func TestGenerateDocLinks(t *testing.T) {
+	xml := strings.Replace(testProjectXML, `desc="Cut out the motors."`,
+		`desc="Cut out the motors.\n Then, event [FlyingState](#1-4-1) is triggered, or [Unknown](#9-9-9) (#9-9-9)."`, 1)
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	code := generateString(t, xml)
+
+	checkContains(t, code,
+		"Then, event [Ardrone3PilotingStateFlyingStateChanged] is triggered, or [Unknown](#9-9-9) (#9-9-9).",
+	)
+	if n := strings.Count(logBuf.String(), "dangling reference #9-9-9"); n != 1 {
+		t.Errorf("got %v warnings about the dangling reference, want 1:\n%v", n, logBuf.String())
+	}
+
+	// A reference without a name is replaced by the link within the
+	// parentheses.
+	g, err := newGenerator(&bytes.Buffer{}, Options{})
+	if err != nil {
+		t.Fatalf("newGenerator: %v", err)
+	}
+	g.refs["135-2"] = parseString(t, testFeatureXML).Projects[0].Msgs[1]
+	if got := g.docText("when [scanned](#135-2) or (#135-2)"); got != "when [WifiScannedItem] or ([WifiScannedItem])" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestGenerateNamedDocLinks(t *testing.T) {
+	xml := strings.Replace(testFeatureXML, `desc="Launches wifi network scan."`,
+		`desc="Launches wifi network scan.\n See [ScannedItem](#wifi-ScannedItem), (#wifi-scan) and [Country](#wifi-Country)."`, 1)
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	code := generateString(t, xml)
+
+	// The names are found as written, or without case and underscores.
+	checkContains(t, code,
+		"See [WifiScannedItem], ([WifiScan]) and [Country](#wifi-Country).",
+	)
+	if n := strings.Count(logBuf.String(), "dangling reference #wifi-Country"); n != 1 {
+		t.Errorf("got %v warnings about the dangling reference, want 1:\n%v", n, logBuf.String())
+	}
+}
+
+func TestGenerateMergedVarNames(t *testing.T) {
+	proto := parseString(t, testProjectXML)
+	other := parseString(t, strings.Replace(strings.Replace(testProjectXML, `name="ardrone3"`, `name="jpsumo"`, 1), `id="1"`, `id="3"`, 1))
+	proto.Projects = append(proto.Projects, other.Projects...)
+
+	var buf bytes.Buffer
+	if err := Generate(&buf, proto, Options{}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	checkContains(t, buf.String(),
+		"var Ardrone3PilotingPCMDCmd = Ardrone3PilotingPCMD{",
+		"var JpsumoPilotingPCMDCmd = JpsumoPilotingPCMD{",
+		"Command(Ardrone3PilotingPCMDCmd): Ardrone3PilotingPCMDCmd,",
+	)
+	if strings.Contains(buf.String(), "var PilotingPCMD ") {
+		t.Errorf("generated code has the variable PilotingPCMD used by both projects")
+	}
+}
+
+func TestGenerateHeader(t *testing.T) {
+	var buf bytes.Buffer
+	opts := Options{
+		Package:   "drone",
+		BuildTags: []string{"linux", "arm"},
+		Sources:   []string{"ardrone3.xml"},
+		Checksum:  "sha256:0123",
+	}
+	if err := Generate(&buf, parseString(t, testProjectXML), opts); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	want := `// Code generated by lexmlparser from ardrone3.xml. DO NOT EDIT.
+// Source checksum: sha256:0123
+
+//go:build linux && arm
+// +build linux,arm
+
+package drone
+`
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("got header\n%v\nwant\n%v", buf.String()[:len(want)], want)
+	}
+}
+
+func TestGenerateMultisetting(t *testing.T) {
+	proto := parseString(t, testMultisettingXML)
+	proto.Projects = append(proto.Projects, parseString(t, testProjectXML).Projects...)
+
+	var buf bytes.Buffer
+	if err := Generate(&buf, proto, Options{}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	checkContains(t, buf.String(),
+		"type GenericDroneSettingsMultisetting struct {\nArdrone3PilotingPCMD Ardrone3PilotingPCMDArguments\nArdrone3PilotingPCMDIsSet bool\n",
+		"if m.Ardrone3PilotingPCMDIsSet {\nmembers = arsdk.AppendMultisettingMember(members, Command(PilotingPCMD), m.Ardrone3PilotingPCMD)\n}\n",
+		"case Command(PilotingPCMD):\nargs, err := PilotingPCMD.Decode(v.Payload)\n",
+		"Settings GenericDroneSettingsMultisetting `arsdk:\"settings\"`",
+		"n, err := arg.Settings.Decode(b[offset:])\n",
+		"b = a.Settings.Encode(b)\n",
+	)
+}
+
+func TestGenerateMultisettingNotFound(t *testing.T) {
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	// The commands of the members are not part of the input.
+	code := generateString(t, testMultisettingXML)
+
+	checkContains(t, code, "Settings []byte `arsdk:\"settings\"`")
+	if strings.Contains(code, "GenericDroneSettingsMultisetting") {
+		t.Errorf("the multisetting type was created without the commands of its members")
+	}
+	if !strings.Contains(logBuf.String(), "member ardrone3.Piloting.PCMD not found") {
+		t.Errorf("got no warning about the member not found:\n%v", logBuf.String())
+	}
+
+	xml := strings.Replace(testMultisettingXML, "multisetting:DroneSettings", "multisetting:CameraSettings", 1)
+	err := Generate(&bytes.Buffer{}, parseString(t, xml), Options{})
+	if err == nil || !strings.Contains(err.Error(), `argument settings: multisetting "CameraSettings" is not declared`) {
+		t.Errorf("got error %v, want an error about the undeclared multisetting", err)
+	}
+}
@@ -2,7 +2,4 @@ module github.com/postmannen/lexmlparser
 
 go 1.15
 
-require (
-	github.com/go-acme/lego v2.7.2+incompatible
-	github.com/postmannen/lexml v0.0.0-20190413205615-d34a5a4dafef
-)
+require github.com/postmannen/lexml v0.0.0-20190413205615-d34a5a4dafef
@@ -0,0 +1,426 @@
+/*
+Package model is the intermediate representation of the Parrot ARSDK
+protocol xml files.
+
+The lexmlparser package fills the model from the tokens produced by the
+lexml package, and the code generators use the model as their input
+instead of working directly on the token stream. This makes it possible
+to inspect, validate and reuse what was parsed without parsing the xml
+again.
+
+The hierarchy of the model follows the xml files:
+
+	Protocol -> Project -> Class -> Cmd -> Arg -> Enum -> Value
+
+The newer xml files are declared with a <feature> tag instead of a
+<project> tag, and have their commands and events directly within the
+feature without any class level. A feature is stored as a Project with
+Feature set to true, and its messages in Msgs.
+
+A feature can also declare multisettings, which group commands of other
+projects by links like ardrone3.PilotingSettings.MaxAltitude. The links
+are resolved with Protocol.Link, so the commands can be declared in
+other xml files parsed into the same protocol.
+*/
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Protocol is the root of the model, and holds all the projects parsed.
+type Protocol struct {
+	Projects []*Project
+}
+
+// Project is a <project> or a <feature> in the xml.
+type Project struct {
+	Name    string
+	ID      int
+	Comment string
+	// Feature is true if the project was declared with a <feature> tag.
+	Feature bool
+	Classes []*Class
+	// Msgs are the commands and events of a feature.
+	Msgs []*Cmd
+	// Enums are the enums declared for the whole feature, which the
+	// arguments refer to by name with a type like enum:<name>.
+	Enums []*Enum
+	// Multisettings are the multisettings declared for the whole
+	// feature, which the arguments refer to by name with a type like
+	// multisetting:<name>.
+	Multisettings []*Multisetting
+}
+
+// Enum will return the feature level enum with the given name, or
+// nil if the project have no such enum.
+func (p *Project) Enum(name string) *Enum {
+	for _, v := range p.Enums {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Multisetting will return the multisetting with the given name, or
+// nil if the project have no such multisetting.
+func (p *Project) Multisetting(name string) *Multisetting {
+	for _, v := range p.Multisettings {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Multisetting is a <multisetting> in the xml, and groups commands of
+// other projects so they can be sent together in a single argument.
+type Multisetting struct {
+	Name    string
+	Comment string
+	Members []*Member
+	// Project is the feature the multisetting belongs to.
+	Project *Project
+}
+
+// Member is a <member> of a multisetting.
+type Member struct {
+	// Link is the command as written in the xml, like
+	// ardrone3.PilotingSettings.MaxAltitude for the command of a class,
+	// or camera.set_mode for the message of a feature. The command is
+	// found with Protocol.Link.
+	Link string
+}
+
+// Class is a <class> in the xml, and groups several commands together.
+type Class struct {
+	Name    string
+	ID      int
+	Comment string
+	Cmds    []*Cmd
+	// Project is the project the class belongs to.
+	Project *Project
+}
+
+// Buffer is the hint given in the xml about what network buffer a
+// command should be sent on.
+type Buffer string
+
+// The buffer values allowed by the xml. ACK is the default if no
+// buffer attribute is given.
+const (
+	BufferNonAck   Buffer = "NON_ACK"
+	BufferAck      Buffer = "ACK"
+	BufferHighPrio Buffer = "HIGH_PRIO"
+)
+
+// Timeout is the policy to use when a command sent on an acknowledged
+// buffer was not acknowledged in time.
+type Timeout string
+
+// The timeout values allowed by the xml. POP is the default if no
+// timeout attribute is given.
+const (
+	TimeoutPop   Timeout = "POP"
+	TimeoutRetry Timeout = "RETRY"
+	TimeoutFlush Timeout = "FLUSH"
+)
+
+// ListType is given by the type attribute of a command, and tells if
+// the command is an item of a list, which is sent as one command for
+// every item.
+type ListType string
+
+// The list types allowed by the xml. A command without a type
+// attribute is not an item of a list.
+const (
+	ListNone ListType = ""
+	ListItem ListType = "LIST_ITEM"
+	MapItem  ListType = "MAP_ITEM"
+)
+
+// Cmd is a <cmd> in the xml, or an <evt> of a feature.
+type Cmd struct {
+	Name string
+	ID   int
+	// Event is true if the message was declared with an <evt> tag.
+	Event      bool
+	Comment    Comment
+	Buffer     Buffer
+	Timeout    Timeout
+	Deprecated bool
+	ListType   ListType
+	// MapKey is the name of the argument which is the key of the item
+	// when ListType is MAP_ITEM. It is given in the xml like
+	// MAP_ITEM:cam_id, and is the first argument if not given.
+	MapKey string
+	Args   []*Arg
+	// Expectations are the messages expected to be received after the
+	// command was sent, in the order they are expected.
+	Expectations []*Expectation
+	// Class is the class the command belongs to, and is nil for the
+	// messages of a feature.
+	Class *Class
+	// Project is the project or feature the command belongs to.
+	Project *Project
+}
+
+// ClassID will return the id of the class the command belongs to.
+// The messages of a feature are sent with a class id of 0.
+func (c *Cmd) ClassID() int {
+	if c.Class == nil {
+		return 0
+	}
+	return c.Class.ID
+}
+
+// ListFlags will return the list_flags argument of a list item, which
+// tells where the item is in the list, or nil if the command have no
+// such argument. The items of the older projects have no list_flags,
+// and every item is sent on its own.
+func (c *Cmd) ListFlags() *Arg {
+	if c.ListType == ListNone {
+		return nil
+	}
+	return c.Arg("list_flags")
+}
+
+// Link will return the name of the command with the names of its
+// project and class, like ardrone3.Piloting.TakeOff, or like
+// camera.set_mode for the message of a feature. The command is found
+// again with Protocol.Link.
+func (c *Cmd) Link() string {
+	if c.Class == nil {
+		return c.Project.Name + "." + c.Name
+	}
+	return c.Project.Name + "." + c.Class.Name + "." + c.Name
+}
+
+// IsEvent will return true if the command is an event sent by the drone.
+// These are the messages of a feature declared with an <evt> tag, and
+// the commands of the classes named like PilotingState, PilotingEvent or
+// ButtonEvents, since the classic projects have no <evt> tag.
+func (c *Cmd) IsEvent() bool {
+	if c.Class == nil {
+		return c.Event
+	}
+	for _, suffix := range []string{"State", "Event", "Events"} {
+		if strings.HasSuffix(c.Class.Name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsState will return true if the command is an event holding a state
+// of the drone, like the battery level or the flying state, where only
+// the last one received is of interest. These are the commands of the
+// classes named like PilotingState, and the events of a feature, except
+// for the items of a list and the events named like grab_button_event.
+func (c *Cmd) IsState() bool {
+	if c.ListType != ListNone {
+		return false
+	}
+	if c.Class != nil {
+		return strings.HasSuffix(c.Class.Name, "State")
+	}
+	return c.Event && !strings.HasSuffix(c.Name, "_event")
+}
+
+// Arg will return the argument with the given name, or nil if the
+// command have no such argument.
+func (c *Cmd) Arg(name string) *Arg {
+	for _, v := range c.Args {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Expectation is a message expected to be received after a command
+// was sent, as given within the <expectations> of the command.
+type Expectation struct {
+	// Delayed is true if the expectation was given within <delayed>,
+	// and false if it was given within <immediate>.
+	Delayed bool
+	// OneOf are the messages which meet the expectation. Most have a
+	// single message, but alternatives are given separated by "|".
+	OneOf []*ExpectedMsg
+}
+
+// ExpectedMsg is a reference to an expected message like
+// #1-4-1(state: motor_ramping), with the values expected for its
+// arguments.
+type ExpectedMsg struct {
+	// Ref is the reference as written in the xml, like #1-4-1 for the
+	// project, class and command ids, or #134-3 for the feature and
+	// message ids.
+	Ref     string
+	Project int
+	Class   int
+	Cmd     int
+	Args    []*ArgPredicate
+}
+
+// ArgPredicate is the value expected for an argument of an expected
+// message.
+type ArgPredicate struct {
+	Name string
+	// Value is the expected value, like the name of an enum value.
+	Value string
+	// This is the name of the argument of the command sent which the
+	// value is expected to be equal to, given as this.<name> in the
+	// xml. Value is empty if This is set.
+	This string
+}
+
+// Comment is the <comment> of a command or an argument.
+type Comment struct {
+	Title     string
+	Desc      string
+	Support   string
+	Result    string
+	Triggered string
+}
+
+// Arg is an <arg> of a command.
+type Arg struct {
+	Name string
+	// Type is the type as written in the xml, like u8, string or enum.
+	Type    string
+	Comment string
+	// Enum holds the values of the argument when Type is enum.
+	Enum *Enum
+}
+
+// Enum is a set of named values.
+type Enum struct {
+	Name    string
+	Comment string
+	Values  []*Value
+}
+
+// Value is a single named value of an Enum.
+type Value struct {
+	Name    string
+	Comment string
+	// Value is the numeric value sent on the wire.
+	Value int
+}
+
+// RemoveDeprecated will remove all the commands and events marked as
+// deprecated from the protocol.
+func (p *Protocol) RemoveDeprecated() {
+	for _, project := range p.Projects {
+		for _, class := range project.Classes {
+			class.Cmds = removeDeprecated(class.Cmds)
+		}
+		project.Msgs = removeDeprecated(project.Msgs)
+	}
+}
+
+func removeDeprecated(cmds []*Cmd) []*Cmd {
+	var kept []*Cmd
+	for _, v := range cmds {
+		if !v.Deprecated {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// Link will return the command referred to by a link like
+// ardrone3.PilotingSettings.MaxAltitude, with the project, class and
+// command names, or like camera.set_mode with the feature and message
+// names. Nil is returned if no such command is found.
+func (p *Protocol) Link(link string) *Cmd {
+	names := strings.Split(link, ".")
+
+	for _, project := range p.Projects {
+		if project.Name != names[0] {
+			continue
+		}
+		switch len(names) {
+		case 2:
+			for _, cmd := range project.Msgs {
+				if cmd.Name == names[1] {
+					return cmd
+				}
+			}
+		case 3:
+			for _, class := range project.Classes {
+				if class.Name != names[1] {
+					continue
+				}
+				for _, cmd := range class.Cmds {
+					if cmd.Name == names[2] {
+						return cmd
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate will check that the projects and features have unique names
+// and ids, and that the ids of the classes and commands are unique
+// within the project, class or feature they belong to. The ids are what
+// identifies a command on the wire, so a collision makes it impossible
+// to know what command was received.
+func (p *Protocol) Validate() error {
+	names := map[string]*Project{}
+	ids := map[int]*Project{}
+
+	for _, project := range p.Projects {
+		if v, ok := names[project.Name]; ok {
+			return fmt.Errorf("%v: name is already used by the project with id %v", project, v.ID)
+		}
+		names[project.Name] = project
+		if v, ok := ids[project.ID]; ok {
+			return fmt.Errorf("%v: id is already used by %v", project, v)
+		}
+		ids[project.ID] = project
+
+		if err := validateCmds(project.Msgs); err != nil {
+			return fmt.Errorf("%v: %v", project, err)
+		}
+
+		classIDs := map[int]*Class{}
+		for _, class := range project.Classes {
+			if v, ok := classIDs[class.ID]; ok {
+				return fmt.Errorf("%v: class %v: id %v is already used by class %v", project, class.Name, class.ID, v.Name)
+			}
+			classIDs[class.ID] = class
+
+			if err := validateCmds(class.Cmds); err != nil {
+				return fmt.Errorf("%v: class %v: %v", project, class.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateCmds(cmds []*Cmd) error {
+	ids := map[int]*Cmd{}
+	for _, cmd := range cmds {
+		if v, ok := ids[cmd.ID]; ok {
+			return fmt.Errorf("%v: id %v is already used by %v", cmd.Name, cmd.ID, v.Name)
+		}
+		ids[cmd.ID] = cmd
+	}
+	return nil
+}
+
+func (p *Project) String() string {
+	kind := "project"
+	if p.Feature {
+		kind = "feature"
+	}
+	return fmt.Sprintf("%v %v (id %v)", kind, p.Name, p.ID)
+}
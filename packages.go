@@ -0,0 +1,108 @@
+package lexmlparser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/postmannen/lexmlparser/model"
+)
+
+// registryName is the name of the package holding the commands of all
+// the packages created by GeneratePackages.
+const registryName = "registry"
+
+// GeneratePackages will write the Go code for every project in the
+// protocol model to its own package, in a directory below dir named as
+// the package. A registry package with a CommandMap holding the commands
+// of all the packages is written to the registry directory below dir.
+// importPath is the import path of dir, which is used by the packages to
+// import each other. The package name given in opts is not used, since
+// the packages are named after the projects.
+func GeneratePackages(dir string, importPath string, proto *model.Protocol, opts Options) error {
+	packages := map[*model.Project]string{}
+	for _, project := range proto.Projects {
+		packages[project] = path.Join(importPath, packageName(project))
+	}
+	// The warnings about dangling references are shared by all the
+	// packages, so each of them are only warned about once.
+	dangling := map[string]bool{}
+
+	for _, project := range proto.Projects {
+		g, err := newGenerator(nil, opts)
+		if err != nil {
+			return err
+		}
+		g.project = project
+		g.packages = packages
+		g.dangling = dangling
+		g.buildTables(proto, []*model.Project{project})
+
+		name := packageName(project)
+		err = createFile(filepath.Join(dir, name, name+".go"), func(w io.Writer) error {
+			return g.generate(w, name, []*model.Project{project}, opts)
+		})
+		if err != nil {
+			return fmt.Errorf("%v: %v", project, err)
+		}
+	}
+
+	return createFile(filepath.Join(dir, registryName, registryName+".go"), func(w io.Writer) error {
+		return printRegistry(w, proto, packages, opts)
+	})
+}
+
+// printRegistry will print the registry package, which merges the
+// CommandMap of all the packages into a single map.
+func printRegistry(w io.Writer, proto *model.Protocol, packages map[*model.Project]string, opts Options) error {
+	data := RegistryData{Name: registryName}
+	for _, project := range proto.Projects {
+		data.Imports = append(data.Imports, packages[project])
+	}
+	sort.Strings(data.Imports)
+
+	t := opts.Templates
+	if t == nil {
+		t = defaultTemplates
+	}
+
+	var src bytes.Buffer
+	printHeader(&src, opts)
+	err := t.ExecuteTemplate(&src, "registry", data)
+	if err != nil {
+		return fmt.Errorf("template registry: %v", err)
+	}
+
+	return writeSource(w, src.Bytes())
+}
+
+// packageName will return the name of the package created for the
+// project, which is the project name in lower case without underscores.
+func packageName(project *model.Project) string {
+	return strings.ToLower(strings.Replace(project.Name, "_", "", -1))
+}
+
+// createFile will create the file with the given name together with
+// the directory it is in, and write to it with write.
+func createFile(name string, write func(w io.Writer) error) error {
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	err = write(fh)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package lexmlparser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratePackages(t *testing.T) {
+	proto := parseString(t, testProjectXML)
+	proto.Projects = append(proto.Projects, parseString(t, testFeatureXML).Projects...)
+
+	dir := t.TempDir()
+	err := GeneratePackages(dir, "example.com/drone", proto, Options{})
+	if err != nil {
+		t.Fatalf("GeneratePackages: %v", err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		return string(b)
+	}
+
+	checkContains(t, read("ardrone3/ardrone3.go"),
+		"package ardrone3\n",
+		"var PilotingPCMD = Ardrone3PilotingPCMD{",
+		"var CommandMap = map[Command]Decoder{",
+	)
+	checkContains(t, read("wifi/wifi.go"),
+		"package wifi\n",
+		// The list_flags enum is not part of the input, and is created in
+		// the package using it.
+		"type GenericListFlagsEnum uint32",
+	)
+	checkContains(t, read("registry/registry.go"),
+		"package registry\n",
+		"\t\"example.com/drone/ardrone3\"\n\t\"example.com/drone/wifi\"\n",
+		"ardrone3.CommandMap,\nwifi.CommandMap,\n",
+		"ardrone3.StateCommands,\nwifi.StateCommands,\n",
+		"var Registry = arsdk.NewRegistry(\nardrone3.Commands,\nwifi.Commands,\n)",
+	)
+}
@@ -13,15 +13,15 @@ Package lexmlparser ,
 package lexmlparser
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
-	"unicode"
-	"unicode/utf8"
-
-	"github.com/go-acme/lego/log"
 
 	"github.com/postmannen/lexml"
+	"github.com/postmannen/lexmlparser/model"
 )
 
 // Define all the toke types.
@@ -33,865 +33,622 @@ const tokenDescription lexml.TokenType = "tokenDescription"     // Description,
 const tokenEOF lexml.TokenType = "tokenEOF"                     //End Of File
 const tokenJustText lexml.TokenType = "tokenJustText"           //just text, no start or end tag
 
-// tokenChannelbufferSize is set way too big. I believe 30-35 would be ok for this xml.
-const tokenChannelbufferSize = 100
-
-// parser will hard the state of the parsing variables.
+// parser will hold the state of the parsing variables.
 type parser struct {
-	// variablesForMap is a slice and are the collection of the tags used to form a map value
-	// in the parsed output (and not a map value in this code here).
-	// Essentially it will contains a command variable, and will be used at the end of the
-	// code to create the key/values of the map structure in the output.
-	variablesForMap []string
-	// commandConstants/classConstants is a store for all the constants parsed, and are used in the code
-	// to check if a constant with the same name has previosly beeing parsed to avoid
-	// duplicated
-	commandConstants map[string]bool
-	classConstants   map[string]bool
-	// tagStack , are a push/pop storage for stack values.
-	// The contents of the tag stack is used to create names
-	// that consists of several tag names.
+	// tagStack , are a push/pop storage for the elements we are
+	// currently inside. The last element on the stack is the one
+	// that new attributes, text and child elements belong to.
 	tagStack *tagStack
-	// depth is used to indicate what level or sub level we are in the struct/tag
-	// to keep track of if we are working on a tag within another tag, and so on.
-	// We add 1 to the depth for each tag we find, and subtract by 1 for each
-	// closing tag.
-	depth int
-	// droneTypesToGoTypes is a map used to know how to map the types found in the xml like
-	// u8/i8/float etc to they're go equivalent.
-	droneTypesToGoTypes map[string]goType
-	// duplicateClassCh is used to send a signal to printing functions for class variables
-	// that there is a duplicate to make the variable unique.
-	duplicateClassCh chan bool
-	// output is where to redirect the output of the printing.
-	output *os.File
+	// attributesOpen is true while we are still reading the attributes
+	// of the last start tag. The lexer will also produce argument tokens
+	// for text containing an = sign, so argument tokens are only used
+	// when they follow directly after a start tag.
+	attributesOpen bool
+	// attributeName is the name of the last argument found, which the
+	// next argument value belongs to.
+	attributeName string
 }
 
-type goType struct {
-	name   string
-	length string
-}
-
-/*
-u8 1 unsigned 8bit value
-i8 1 signed 8bit value
-u16 2 unsigned 16bit value
-i16 2 signed 16bit value
-u32 4 unsigned 32bit value
-i32 4 signed 32bit value
-u64 8 unsigned 64bit value
-i64 8 signed 64bit value
-float 4 IEEE-754 single precision
-double 8 IEEE-754 double precision
-string * Null terminated string (C-String)
-(Variable size)
-enum 4 Per command defined enum
-*/
-
 // newParser will return a new *parser struct that will hold the state of the
 // parsing while parsing.
-func newParser(outFh *os.File) *parser {
-	return &parser{
-		variablesForMap:  []string{},
-		commandConstants: map[string]bool{},
-		classConstants:   map[string]bool{},
-		tagStack:         newTagStack(),
-		depth:            0,
-		droneTypesToGoTypes: map[string]goType{
-			"u8":     goType{name: "uint8", length: "1"},
-			"i8":     goType{name: "int8", length: "1"},
-			"u16":    goType{name: "uint16", length: "2"},
-			"i16":    goType{name: "int16", length: "2"},
-			"u32":    goType{name: "uint32", length: "4"},
-			"i32":    goType{name: "int32", length: "4"},
-			"u64":    goType{name: "uint64", length: "8"},
-			"i64":    goType{name: "int64", length: "8"},
-			"float":  goType{name: "float32", length: "4"},
-			"double": goType{name: "float64", length: "8"},
-			"string": goType{name: "string", length: "0"},
-			"enum":   goType{name: "uint32", length: "4"},
-		},
-		duplicateClassCh: make(chan bool, 2),
-		output:           outFh,
+func newParser() *parser {
+	p := &parser{
+		tagStack: newTagStack(),
 	}
-}
+	// The root element does not exist in the xml, but gives the
+	// top level tags like project a parent to be added to.
+	p.tagStack.push(&element{attributes: map[string]string{}})
 
-// Start will start the lexml parser. Takes a channel of tokens as it's input.
-func Start(tCh chan lexml.Token, outFh *os.File) {
-	// Create a new parser
-	p := newParser(outFh)
-
-	// Create a buffered reader of the channel. The .Next method will move to
-	// the next value from input channel. The buffered reader will let us
-	// look at the values that are comming ahead of where we are right now.
-	buf := NewBuffer(tokenChannelbufferSize)
-	buf.Start(tCh)
-
-	fmt.Fprintln(p.output, "package main")
-	fmt.Fprintln(p.output)
-
-	p.printTopDeclarations()
-
-	// Range over the ChOut of buf, where ChOut is an unbuffered channel,
-	// and we can pick one value at a time.
-	for v := range buf.ChOut {
-		switch v.TokenType {
-
-		// Everything we want parse into something else starts with
-		// a start tag. If a start tag is found we should check
-		// for tags between startTag -> endTag for the type we are
-		// looking for.
-		//
-		// Check all the start tags.
-		case tokenStartTag:
-			p.doTokenTagStart(buf)
-		// Check all the end tags
-		case tokenEndTag:
-			//*fmt.Println("endTag-------------------------------------------------------", v)
-			//*fmt.Printf("depth = %v, endtag found : %v, subtracting to depth.\n", depth, v.TokenText)
-
-			p.depth--
-			p.tagStack.pop()
-			//*fmt.Println("Depth is now = ", depth)
-		}
+	return p
+}
 
-		// Read the next token from the buffered channel.
-		buf.ReadNext()
+// Start will start the lexml parser. Takes a channel of tokens as it's input,
+// and writes the generated Go code to outFh.
+func Start(tCh chan lexml.Token, outFh *os.File) error {
+	proto, err := Parse(tCh)
+	if err != nil {
+		return err
 	}
 
-	p.printMapDeclaration()
-
-	p.printBuiltinFunctions()
-
-	p.printFuncgetLengthOfStringData()
+	return Generate(outFh, proto, Options{})
+}
 
-	p.printConvLittleEndianSliceToNumeric()
+// Parse will read all the tokens from the channel, and return the
+// protocol model of the parsed xml.
+func Parse(tCh chan lexml.Token) (*model.Protocol, error) {
+	p := newParser()
 
-	p.printConvLittleEndianNumericToSlice()
+	// Range over all the tokens, and build the element tree. We always
+	// read the channel until it is closed, so the lexer is never left
+	// blocking on a send.
+	for v := range tCh {
+		p.doToken(v)
+	}
 
+	return doDocument(p.tagStack.data[0])
 }
 
-// doTokenTagStart will do all the parsing of a tagStart.
-func (p *parser) doTokenTagStart(buf *Buffer) {
-	//*fmt.Println("startTag-------------------------------------------------------", v)
-	//*fmt.Printf("depth = %v, startTag found : %v, adding to depth.\n", depth, v.TokenText)
-	//
-	// Push the name of the tag found on the tag Stack.
-	p.depth++
-	p.tagStack.push(buf.Slice[2].TokenText)
-	//*fmt.Println("Depth is now = ", depth)
-
-	// Get the first 2 sequences of tokens that have a start and stop tag in the buffer.
-	tmpBuf1, tmpBuf2 := newPartialBuffer(buf)
-
-	//Remove later, just for showing values
-	//fmt.Println()
-	//for _, v := range tmpBuf1 {
-	//	fmt.Printf("*** tmpBuf1 : %v\n", v)
-	//}
-	//
-	////Remove later, just for showing values
-	//fmt.Println()
-	//for _, v := range tmpBuf2 {
-	//	fmt.Printf("*** tmpBuf2 : %v\n", v)
-	//}
-
-	// Range the buffer for this specific token
-	for i, v := range tmpBuf1 {
-		//*fmt.Printf("tmpBuf : %+v\n", v)
-		// If there is an id value we will know that it is a project/class/cmd tag.
-		if v.TokenText == "id" {
-			id := tmpBuf1[i+1].TokenText
-
-			//Check if it is either project, class or cmd tag.
-			switch tmpBuf1[0].TokenText {
-
-			case "project":
-				p.doTagProject(tmpBuf1, tmpBuf2, id)
-			case "class":
-				p.doTagClass(tmpBuf1, tmpBuf2, id)
-			case "cmd":
-				// We need a buffer of all the arguments that belong to that specific cmd,
-				// since the use of the arguments will be mixed with the cmd in the
-				// generated output text.
-				argBuf, err := p.newArgBufferForCmd(buf)
-				if err != nil {
-					log.Println("error: newArgBufferForCmd: ", err)
-				}
-
-				//fmt.Printf("------------ARGBUFFER----------- %+v\n", argBuf)
-
-				p.doTagCommand(tmpBuf1, tmpBuf2, id, argBuf)
-			}
-
+// ParseFiles will parse all the xml files given, and merge them into a
+// single protocol model. An error is returned if the projects and
+// features of the files have colliding names or ids.
+func ParseFiles(names ...string) (*model.Protocol, error) {
+	proto := &model.Protocol{}
+
+	// The files are lexed one at a time, since the lexer can only lex a
+	// single file at a time.
+	for _, name := range names {
+		fh, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := Parse(lexml.LexStart(fh))
+		fh.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
 		}
+		proto.Projects = append(proto.Projects, v.Projects...)
+	}
+
+	if err := proto.Validate(); err != nil {
+		return nil, err
 	}
 
+	return proto, nil
 }
 
-// doTagProject will do all the parsing of a project tag.
-func (p *parser) doTagProject(tmpBuf1 []lexml.Token, tmpBuf2 []lexml.Token, id string) {
-	for _, v := range tmpBuf1 {
-		if v.TokenType == tokenDescription {
-			fmt.Fprintf(p.output, "// %v\n", v.TokenText)
-			break
+// Checksum will return the SHA-256 checksum of the content of the xml
+// files given, in the order given, formatted like sha256:<hex>.
+func Checksum(names ...string) (string, error) {
+	h := sha256.New()
+
+	for _, name := range names {
+		fh, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, fh)
+		fh.Close()
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", name, err)
 		}
 	}
 
-	name := tmpBuf1[2]
-	fmt.Fprintf(p.output, "const Project%v ProjectDef = %v\n", upperFirstCharacter(name.TokenText), id)
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
 }
 
-// doTagClass will do all the parsing of a class tag.
-func (p *parser) doTagClass(tmpBuf1 []lexml.Token, tmpBuf2 []lexml.Token, id string) {
-	// Check if there is a tokenDescription tag
-	for _, v := range tmpBuf1 {
-		if v.TokenType == tokenDescription {
-			fmt.Fprintf(p.output, "// %v\n", v.TokenText)
-			break
+// doToken will add a single token to the element tree.
+func (p *parser) doToken(v lexml.Token) {
+	current := p.tagStack.top()
+
+	switch v.TokenType {
+	case tokenStartTag:
+		e := &element{name: v.TokenText, attributes: map[string]string{}}
+		current.children = append(current.children, e)
+		p.tagStack.push(e)
+		p.attributesOpen = true
+		p.attributeName = ""
+	case tokenArgumentName:
+		if p.attributesOpen {
+			p.attributeName = v.TokenText
+		}
+	case tokenArgumentValue:
+		if p.attributesOpen && p.attributeName != "" {
+			current.attributes[p.attributeName] = v.TokenText
+			p.attributeName = ""
+		}
+	case tokenDescription, tokenJustText:
+		p.attributesOpen = false
+		if current.text != "" {
+			current.text += " "
+		}
+		current.text += v.TokenText
+	case tokenEndTag:
+		p.attributesOpen = false
+		// The lexer can produce end tags for a > found inside an
+		// attribute value, so only the end tag matching the element
+		// we are inside will close it.
+		if current.name == v.TokenText && len(p.tagStack.data) > 1 {
+			p.tagStack.pop()
 		}
 	}
-
-	//--
-
-	// The name of the command const is found at slice pos [2].
-	classConstName := tmpBuf1[2]
-
-	fmt.Fprintf(p.output, "const %v%vClass%v ClassDef = %v\n", upperFirstCharacter(p.tagStack.data[0]), upperFirstCharacter(p.tagStack.data[1]), upperFirstCharacter(classConstName.TokenText), id)
-	fmt.Fprintf(p.output, "// *** %v\n", p.tagStack.data)
-
 }
 
-// doTagCommand will do all the parsing of a command tag
-func (p *parser) doTagCommand(tmpBuf1 []lexml.Token, tmpBuf2 []lexml.Token, id string, argBuf []argument) {
-	// TODO: Add parsing of buffer="NON_ACK", and add a field in the command struct
-	// that we can check to know when to send an ack or not.
-	// NB: I'm not really sure if the parsing of NON_ACK is actually needed, since
-	// it seems that the protocol specification says that if an ACK should be sent
-	// is dependant of the buffer in use.
-	// For the Bebop Drone, the NON_ACK buffers are 10 (c2d) and 127 (d2c),
-	// the ACK buffers are 11 (c2d) and 126 (d2c), and the HIGH_PRIO buffer is the
-	// 12 (c2d).
-
-	// ----------------------------------------------------------------------------------
-	// -------------------------CREATE COMMENTS------------------------------------------
-
-	// Check if there are comments to be printed for the command.
-	//
-	// The startToken..if found, is located in the 0'th position of the buffer.
-	if tmpBuf2[0].TokenType == tokenStartTag && tmpBuf2[0].TokenText == "comment" {
-		// Create the comments above the const declaration.
-		for i, v := range tmpBuf2 {
-			// We do not want the first value since it is a start tag.
-			if i == 0 {
-				continue
+// doDocument will create the protocol model from the root element of
+// the element tree.
+func doDocument(root *element) (*model.Protocol, error) {
+	proto := &model.Protocol{}
+
+	for _, e := range root.children {
+		switch e.name {
+		case "project":
+			project, err := doTagProject(e)
+			if err != nil {
+				return nil, err
 			}
-			if v.TokenType == tokenArgumentName {
-				fmt.Fprintf(p.output, "// %v : ", v.TokenText)
-			}
-			if v.TokenType == tokenArgumentValue {
-				fmt.Fprintf(p.output, "%v, \n", v.TokenText)
+			proto.Projects = append(proto.Projects, project)
+		case "feature":
+			feature, err := doTagFeature(e)
+			if err != nil {
+				return nil, err
 			}
+			proto.Projects = append(proto.Projects, feature)
 		}
 	}
 
-	// ---------------------------------------------------------------------------------------
-	// -------------------------CREATE CONST AND TYPES----------------------------------------
-	// Create the variable name of the current project->class->command
-	// content in the tagStack.
-	var variableName string
-	for i, v := range p.tagStack.data {
-		// We do not want the first value naming the project
-		// in the variableName value, only class+command.
-		if i != 0 {
-			variableName += v
-		}
-	}
-
-	// The name of the command const is found at slice pos [2].
-	cmdConstname := tmpBuf1[2]
-
-	constName := cmdConstname.TokenText
-	fmt.Fprintf(p.output, "const %v%vCmd%v CmdDef = %v\n", upperFirstCharacter(p.tagStack.data[0]), upperFirstCharacter(p.tagStack.data[1]), upperFirstCharacter(constName), id)
-	fmt.Fprintln(p.output)
+	return proto, nil
+}
 
-	// Create the struct type command which will hold the decode methods
-	// for the command
-	fmt.Fprintf(p.output, "type %v Command\n", upperFirstCharacter(concatenateSlice(p.tagStack.data)))
-	fmt.Fprintln(p.output)
+// doTagProject will do all the parsing of a project tag.
+func doTagProject(e *element) (*model.Project, error) {
+	id, err := e.attributeInt("id")
+	if err != nil {
+		return nil, fmt.Errorf("project %q: %v", e.attributes["name"], err)
+	}
 
-	// TODO: -----------Put in the argument checking and parsing here--------------------
-	// TODO: Store all the arguments in an argument struct with fields needed, since we need to
-	// iterate it here, and we also need to iterate it in the creation of the decode method below.
-	//
-	// Create a specific struct for a specific command, by adding Arguments to the end of the
-	// command name.
-	fmt.Fprintf(p.output, "type %v struct {\n", upperFirstCharacter(concatenateSlice(p.tagStack.data))+"Arguments")
-	for _, v := range argBuf {
-		fmt.Fprintf(p.output, "%v %v\n", upperFirstCharacter(v.name), v.goType)
+	project := &model.Project{
+		Name:    e.attributes["name"],
+		ID:      id,
+		Comment: e.text,
 	}
-	fmt.Fprintln(p.output, "}")
-	fmt.Fprintln(p.output)
-	// TODO: Write out the name of the arguments and the Go equivalent of the type for the fields.
 
-	// -------------------------------------------------------------------------------------------
-	// ----------------------------CREATE DECODE METHOD-------------------------------------------
-	// Create the decode function for the command type
+	for _, c := range e.children {
+		if c.name != "class" {
+			continue
+		}
+		class, err := doTagClass(c, project)
+		if err != nil {
+			return nil, fmt.Errorf("project %q: %v", project.Name, err)
+		}
+		project.Classes = append(project.Classes, class)
+	}
 
-	p.createDecodeMethod(argBuf)
+	return project, nil
+}
 
-	// -------------------------------------------------------------------------------------------
-	// ----------------------------CREATE ENCODE METHOD-------------------------------------------
-	// Create the encode function for the command type
+// doTagFeature will do all the parsing of a feature tag. A feature is
+// like a project, but with the commands and events inside a msgs tag
+// instead of inside classes.
+func doTagFeature(e *element) (*model.Project, error) {
+	id, err := e.attributeInt("id")
+	if err != nil {
+		return nil, fmt.Errorf("feature %q: %v", e.attributes["name"], err)
+	}
 
-	p.createEncodeMethod(argBuf)
+	feature := &model.Project{
+		Name:    e.attributes["name"],
+		ID:      id,
+		Comment: e.text,
+		Feature: true,
+	}
 
-	// -------------------------------------------------------------------------------------------
-	// ----------------------------CREATE VAR BASED ON TYPE---------------------------------------
+	for _, c := range e.children {
+		if c.name == "enums" {
+			feature.Enums = doTagEnums(c)
+			continue
+		}
+		if c.name == "multisettings" {
+			feature.Multisettings = doTagMultisettings(c, feature)
+			continue
+		}
+		if c.name != "msgs" {
+			continue
+		}
+		for _, m := range c.children {
+			if m.name != "cmd" && m.name != "evt" {
+				continue
+			}
+			msg, err := doTagCommand(m)
+			if err != nil {
+				return nil, fmt.Errorf("feature %q: %v", feature.Name, err)
+			}
+			msg.Project = feature
+			feature.Msgs = append(feature.Msgs, msg)
+		}
+	}
 
-	project := p.tagStack.data[0]
-	class := p.tagStack.data[1]
-	command := p.tagStack.data[2]
+	return feature, nil
+}
 
-	fmt.Fprintln(p.output)
-	fmt.Fprintf(p.output, "var %v = %v {\n", upperFirstCharacter(variableName), upperFirstCharacter(concatenateSlice(p.tagStack.data)))
-	fmt.Fprintf(p.output, "Project: Project%v,\n", upperFirstCharacter(project))
+// doTagEnums will do all the parsing of the enums tag of a feature.
+func doTagEnums(e *element) []*model.Enum {
+	var enums []*model.Enum
 
-	fmt.Fprintf(p.output, "Class: %v%vClass%v,\n", upperFirstCharacter(p.tagStack.data[0]), upperFirstCharacter(p.tagStack.data[1]), upperFirstCharacter(class))
-	fmt.Fprintf(p.output, "Cmd: %v%vCmd%v,\n", upperFirstCharacter(p.tagStack.data[0]), upperFirstCharacter(p.tagStack.data[1]), upperFirstCharacter(command))
-	fmt.Fprintf(p.output, "}\n")
-	fmt.Fprintln(p.output)
+	for _, c := range e.children {
+		if c.name != "enum" {
+			continue
+		}
+		enum := &model.Enum{
+			Name:    c.attributes["name"],
+			Comment: c.text,
+		}
+		// The values are numbered in the order they are declared.
+		for _, v := range c.children {
+			if v.name != "value" {
+				continue
+			}
+			enum.Values = append(enum.Values, &model.Value{
+				Name:    v.attributes["name"],
+				Comment: v.text,
+				Value:   len(enum.Values),
+			})
+		}
+		enums = append(enums, enum)
+	}
 
-	// store the variable name in a slice so we can use it
-	// to create the map[command]decoder map later.
-	p.variablesForMap = append(p.variablesForMap, variableName)
+	return enums
 }
 
-func (p *parser) createDecodeMethod(argBuf []argument) {
-	// -------------------------------------------------------------------------------------------
-	// ----------------------------CREATE DECODE METHOD-------------------------------------------
-	// Create the decode function for the command type
-
-	fmt.Fprintf(p.output, "func (a %v) Decode(b []byte) interface{} {\n", upperFirstCharacter(concatenateSlice(p.tagStack.data)))
-	fmt.Fprintf(p.output, "//TODO: .............\n")
-	//txt := `fmt.Printf(".....we are now decoding the payload %v, which is of type %T\n", a, a)`
-	//fmt.Println(txt)
-	//txt = `fmt.Printf("%+v\n", a)`
-	//fmt.Println(txt)
-
-	txt := "arg := " + upperFirstCharacter(concatenateSlice(p.tagStack.data)) + "Arguments" + "{}"
-
-	//if there is a string argument, add variables needed
-	foundStringArg := false
-	for _, v := range argBuf {
-		if v.goType == "string" {
-			foundStringArg = true
-		}
-	}
-
-	if foundStringArg {
-		fmt.Fprintln(p.output, "var stringEnd int")
-		fmt.Fprintln(p.output, "var err error")
-	}
-
-	fmt.Fprintln(p.output, txt)
-
-	if len(argBuf) != 0 {
-		fmt.Fprintln(p.output, "var offset = 0")
-		// Print the parsing of the types for the decode method
-		for _, v := range argBuf {
-
-			// The parsing of everything except a string is the same. Check if string...
-			if v.goType != "string" {
-				// NB: Decoding the bytes to binary with binary.Read is slow compared to
-				// running binary.LittleEndian directly. But the advantage of of Binary.Read
-				// is that it converts the output into the type of the variable you pass into
-				// it.
-				// Binary.LittleEndian need to specify by a mathod what type to convert into,
-				// and that makes it harder to make the parser logic, since it only have uint16,
-				// uint32, and uint64. If we where to use this we would need to make the logic
-				// to translate all values needed by the drone into those 3 types.
-
-				// HERE: Changing
-				//txt := "binary.Read(bytes.NewReader(b[offset:offset+" + v.length + "]), binary.LittleEndian, &arg." + v.name + ")"
-
-				txt := "ConvLittleEndianSliceToNumeric(b[offset:offset+" + v.length + "]," + "&arg." + upperFirstCharacter(v.name) + ")"
-				fmt.Fprintln(p.output, txt)
-
-				// the linter complains for ´arg += 1´, so we add a check and replace it
-				// with arg++ if the length == 1.
-				if v.length != "1" {
-					fmt.Fprintln(p.output, "offset += "+string(v.length))
-				} else {
-					fmt.Fprintln(p.output, "offset++ ")
-				}
-			} else if v.goType == "string" {
-				fmt.Fprintln(p.output, `
-				stringEnd, err = getLengthOfStringData(b[offset:])
-				if err != nil {
-					log.Println("error: ", err)
-				}`)
-
-				fmt.Fprintf(p.output, "arg.%v = string(b[offset:offset+stringEnd])\n", upperFirstCharacter(v.name))
-				fmt.Fprintln(p.output, "offset += stringEnd")
-			}
+// doTagMultisettings will do all the parsing of a multisettings tag,
+// which holds the multisettings declared for a whole feature.
+func doTagMultisettings(e *element, feature *model.Project) []*model.Multisetting {
+	var multisettings []*model.Multisetting
 
+	for _, c := range e.children {
+		if c.name != "multisetting" {
+			continue
 		}
-	} else {
-		fmt.Fprintln(p.output, "// No arguments to decode here !!")
+		ms := &model.Multisetting{
+			Name:    c.attributes["name"],
+			Comment: c.text,
+			Project: feature,
+		}
+		for _, m := range c.children {
+			if m.name != "member" {
+				continue
+			}
+			ms.Members = append(ms.Members, &model.Member{Link: m.attributes["link"]})
+		}
+		multisettings = append(multisettings, ms)
 	}
 
-	fmt.Fprintln(p.output)
-	fmt.Fprintln(p.output, "return arg")
-	fmt.Fprintf(p.output, "}\n")
-
+	return multisettings
 }
 
-func (p *parser) createEncodeMethod(argBuf []argument) {
-	// -------------------------------------------------------------------------------------------
-	// ----------------------------CREATE Encode METHOD-------------------------------------------
-	// Create the encode function for the command type
-
-	fmt.Fprintf(p.output, "func (a %vArguments) Encode() []byte {\n", upperFirstCharacter(concatenateSlice(p.tagStack.data)))
-	fmt.Fprintf(p.output, "//TODO: .............\n")
-
-	txt := `
-		//TODO: .............
+// doTagClass will do all the parsing of a class tag.
+func doTagClass(e *element, project *model.Project) (*model.Class, error) {
+	id, err := e.attributeInt("id")
+	if err != nil {
+		return nil, fmt.Errorf("class %q: %v", e.attributes["name"], err)
+	}
 
-		var bs []byte
-		valueOf := reflect.ValueOf(a)
-		log.Printf("valueOf: %#v\n", valueOf)
+	class := &model.Class{
+		Name:    e.attributes["name"],
+		ID:      id,
+		Comment: e.text,
+		Project: project,
+	}
 
-		fmt.Printf("Number of fields in the struct: %v\n", valueOf.NumField())
-		fmt.Println("--------------Iterating fields-----------------")
-		log.Printf("valueOf.NumField(): %#v\n", valueOf.NumField())
-		for i := 0; i < valueOf.NumField(); i++ {
-			b := ConvLittleEndianNumericToSlice(valueOf.Field(i).Interface())
-			fmt.Printf("mySlice = %#v\n", b)
-		
-			log.Printf("b: %#v\n", b)
-		
-			bs = append(bs, b...)
+	for _, c := range e.children {
+		if c.name != "cmd" {
+			continue
+		}
+		cmd, err := doTagCommand(c)
+		if err != nil {
+			return nil, fmt.Errorf("class %q: %v", class.Name, err)
 		}
-	
-		return bs
+		cmd.Class = class
+		cmd.Project = project
+		class.Cmds = append(class.Cmds, cmd)
 	}
 
-	`
-
-	fmt.Fprintf(p.output, "%v\n", txt)
-}
-
-// ---------------------------------------------------------------------------------------
-
-func (p *parser) printConvLittleEndianNumericToSlice() {
-	text := `
-	// ConvLittleEndianNumericToSlice takes a a value of any of the standard types
-	// uint8/int8/uint16/int16/uint32/int32/uint64/int64/float32/float64
-	// and convert to a []byte.
-	func ConvLittleEndianNumericToSlice(value interface{}) []byte {
-		var b []byte
-
-		switch v := value.(type) {
-		case uint8:
-			b = []byte{byte(v)}
-		case int8:
-			b = []byte{byte(v)}
-		case uint16:
-			b = make([]byte, 2)
-			binary.LittleEndian.PutUint16(b, v)
-		case int16:
-			b = make([]byte, 2)
-			binary.LittleEndian.PutUint16(b, uint16(v))
-		case uint32:
-			b = make([]byte, 4)
-			binary.LittleEndian.PutUint32(b, v)
-		case int32:
-			b = make([]byte, 4)
-			binary.LittleEndian.PutUint32(b, uint32(v))
-		case uint64:
-			b = make([]byte, 8)
-			binary.LittleEndian.PutUint64(b, v)
-		case int64:
-			b = make([]byte, 8)
-			binary.LittleEndian.PutUint64(b, uint64(v))
-		case float32:
-			b = make([]byte, 4)
-			binary.LittleEndian.PutUint32(b, math.Float32bits(v))
-		case float64:
-			b = make([]byte, 8)
-			binary.LittleEndian.PutUint64(b, math.Float64bits(v))
-		case string:
-			b = []byte(v)
-
-		}
-
-		return b
-	}
-	`
-
-	fmt.Fprintln(p.output, text)
+	return class, nil
 }
 
-func (p *parser) printConvLittleEndianSliceToNumeric() {
-	text := `
-	// ConvLittleEndianSliceToNumeric takes a []byte, and an *out variable of type
-	// uint8/int8/uint16/int16/uint32/int32/uint64/int64/float32/float64
-	// and convert the []byte, and places the result into the *out variable.
-	func ConvLittleEndianSliceToNumeric(in []byte, out interface{}) {
-		switch out := out.(type) {
-		case *uint8:
-			*out = uint8(in[0])
-		case *int8:
-			*out = int8(in[0])
-		case *uint16:
-			*out = binary.LittleEndian.Uint16(in)
-		case *int16:
-			*out = int16(binary.LittleEndian.Uint16(in))
-		case *uint32:
-			*out = binary.LittleEndian.Uint32(in)
-		case *int32:
-			*out = int32(binary.LittleEndian.Uint32(in))
-		case *uint64:
-			*out = binary.LittleEndian.Uint64(in)
-		case *int64:
-			*out = int64(binary.LittleEndian.Uint32(in))
-		case *float32:
-			bits := binary.LittleEndian.Uint32(in)
-			*out = math.Float32frombits(bits)
-		case *float64:
-			bits := binary.LittleEndian.Uint64(in)
-			*out = math.Float64frombits(bits)
-		case *string:
-			*out = string(in)
-		}
-	}
-	`
-	fmt.Fprintln(p.output, text)
-}
+// doTagCommand will do all the parsing of a command tag, or an event
+// tag of a feature.
+func doTagCommand(e *element) (*model.Cmd, error) {
+	id, err := e.attributeInt("id")
+	if err != nil {
+		return nil, fmt.Errorf("%v %q: %v", e.name, e.attributes["name"], err)
+	}
 
-// lowerFirstCharacer, turns the first character of a string
-// to lowercase.
-func lowerFirstCharacter(s string) string {
-	if s == "" {
-		return ""
+	cmd := &model.Cmd{
+		Name:       e.attributes["name"],
+		ID:         id,
+		Event:      e.name == "evt",
+		Buffer:     model.BufferAck,
+		Timeout:    model.TimeoutPop,
+		Deprecated: e.attributes["deprecated"] == "true",
 	}
-	r, n := utf8.DecodeRuneInString(s)
-	return string(unicode.ToLower(r)) + s[n:]
-}
 
-// upperFirstCharacer, turns the first character of a string
-// to uppercase.
-func upperFirstCharacter(s string) string {
-	if s == "" {
-		return ""
+	if v, ok := e.attributes["buffer"]; ok {
+		switch b := model.Buffer(v); b {
+		case model.BufferNonAck, model.BufferAck, model.BufferHighPrio:
+			cmd.Buffer = b
+		default:
+			return nil, fmt.Errorf("%v %q: unknown buffer %q", e.name, cmd.Name, v)
+		}
+	}
+	if v, ok := e.attributes["timeout"]; ok {
+		switch t := model.Timeout(v); t {
+		case model.TimeoutPop, model.TimeoutRetry, model.TimeoutFlush:
+			cmd.Timeout = t
+		default:
+			return nil, fmt.Errorf("%v %q: unknown timeout %q", e.name, cmd.Name, v)
+		}
+	}
+	if v, ok := e.attributes["type"]; ok {
+		// The key of a map item is given after the type, like
+		// MAP_ITEM:cam_id.
+		typ, key := v, ""
+		if i := strings.Index(v, ":"); i != -1 {
+			typ, key = v[:i], v[i+1:]
+		}
+		switch t := model.ListType(typ); {
+		case t == model.MapItem, t == model.ListItem && key == "":
+			cmd.ListType = t
+			cmd.MapKey = key
+		default:
+			return nil, fmt.Errorf("%v %q: unknown type %q", e.name, cmd.Name, v)
+		}
 	}
-	r, n := utf8.DecodeRuneInString(s)
-	return string(unicode.ToUpper(r)) + s[n:]
-}
 
-func (p *parser) printBuiltinFunctions() {
-	text := `
-	// lenStringData takes a []byte which is the data for the arguments, and returns
-	// the position of the 0 terminator for the string.
-	// The []byte given as input will start looking from the beginning of the slice,
-	// so the input slice should be sliced to start from the offset of the string.
-	func lenStringData(b []byte) (int, error) {
-		// Figure out the length of the string
-		for i := 0; i < cap(b); i++ {
-			//fmt.Printf("%+v, of type %T\n", b[i], b[i])
-
-			//fmt.Println("i = ", i)
-			if b[i] == 0 {
-				//fmt.Println("lengthString = ", i)
-
-				// add 1 to jump to the 0
-				return i + 1, nil
+	for _, c := range e.children {
+		switch c.name {
+		case "comment":
+			cmd.Comment = doTagComment(c)
+		case "arg":
+			arg := doTagArg(c)
+			// The lexer does not know about xml comments spanning several
+			// lines, and will give us the tags that are commented out. An
+			// argument can only be declared once, so the first one is kept.
+			if cmd.Arg(arg.Name) != nil {
+				continue
 			}
-
+			cmd.Args = append(cmd.Args, arg)
+		case "expectations":
+			exps, err := doTagExpectations(c)
+			if err != nil {
+				return nil, fmt.Errorf("%v %q: %v", e.name, cmd.Name, err)
+			}
+			cmd.Expectations = exps
 		}
+	}
 
-		err := fmt.Errorf("no string bytes found, returning 0")
-		return 0, err
+	if cmd.ListType == model.MapItem {
+		if cmd.MapKey == "" && len(cmd.Args) != 0 {
+			cmd.MapKey = cmd.Args[0].Name
+		}
+		if cmd.Arg(cmd.MapKey) == nil {
+			return nil, fmt.Errorf("%v %q: map key %q is not an argument", e.name, cmd.Name, cmd.MapKey)
+		}
 	}
-	`
-	fmt.Fprintln(p.output, text)
-}
 
-// printTopDeclarations will print things like package ...., func main,
-// imports, etc....
-func (p *parser) printTopDeclarations() {
-	fmt.Fprintln(p.output, "import (")
-	fmt.Fprintln(p.output, `	"fmt"`)
-	fmt.Fprintln(p.output, `	"math"`)
-	fmt.Fprintln(p.output, `	"log"`)
-	fmt.Fprintln(p.output, `	"encoding/binary"`)
-	fmt.Fprintln(p.output, `	"reflect"`)
-	fmt.Fprintln(p.output, ")")
-	fmt.Fprintln(p.output)
-	fmt.Fprintln(p.output, "type ProjectDef uint8 ")
-	fmt.Fprintln(p.output, "type ClassDef uint8")
-	fmt.Fprintln(p.output, "type CmdDef uint16")
-	fmt.Fprintln(p.output)
-	fmt.Fprintln(p.output, "type Command struct {")
-	fmt.Fprintln(p.output, "	Project ProjectDef")
-	fmt.Fprintln(p.output, "	Class   ClassDef")
-	fmt.Fprintln(p.output, "	Cmd     CmdDef")
-	fmt.Fprintln(p.output, "}")
-	fmt.Fprintln(p.output)
+	return cmd, nil
 }
 
-// TODO/NB: In the XML there is a tag value for NON_ACK and HIGH_PRIO.
-// With the specification as follows:
-/*
-	4.4.1 buffer
-	The value of this attribute can be either NON_ACK, ACK or HIGH_PRIO, defaulting to ACK if not given. It gives a hint about the destination buffer for the command.
-	For the Bebop Drone, the NON_ACK buffers are 10 (c2d) and 127 (d2c),
-	the ACK buffers are 11 (c2d) and 126 (d2c), and the HIGH_PRIO buffer is the
-	12 (c2d).
-	This is only a hint, and the product will decode any ARCommand on any
-	ARNetwork buffer, as long as the buffer is not used for ARStream.
-*/
-// Based on the text above I find no reason to parse the ACK flag, since that need to
-// be handled on the protocol level in the drone driver code based on what buffer the
-// command was received on, and the info in the XML is for description.
-
-// printMapDeclaration will print the whole map structure which
-// maps all the command variables to it's type.
-func (p *parser) printMapDeclaration() {
-	// Map for storing the different commands for lookup.
-	fmt.Fprintln(p.output, "type Decoder interface {")
-	fmt.Fprintln(p.output, "Decode([]byte) interface{}")
-	fmt.Fprintln(p.output, "}")
-	fmt.Fprintln(p.output)
-	fmt.Fprintln(p.output, "type Encoder interface {")
-	fmt.Fprintln(p.output, "Encode() []byte")
-	fmt.Fprintln(p.output, "}")
-	fmt.Fprintln(p.output)
-	fmt.Fprintln(p.output, "var CommandMap = map[Command]Decoder {")
-
-	// Will go through the slice and pick out one variable
-	// at a time and create the map value
-	for _, v := range p.variablesForMap {
-		fmt.Fprintf(p.output, "Command(%v) : %v,\n", upperFirstCharacter(v), upperFirstCharacter(v))
-	}
-	fmt.Fprintln(p.output, "}")
-	fmt.Fprintln(p.output)
-}
+// doTagExpectations will parse the <immediate> and <delayed> children
+// of an <expectations> tag.
+func doTagExpectations(e *element) ([]*model.Expectation, error) {
+	var exps []*model.Expectation
 
-func (p *parser) printFuncgetLengthOfStringData() {
-	txt := `
-	func getLengthOfStringData(b []byte) (int, error) {
-		// Figure out the length of the string
-		for i := 0; i < cap(b); i++ {
-			//fmt.Printf("%+v, of type %T\n", b[i], b[i])
-	
-			//fmt.Println("i = ", i)
-			if b[i] == 0 {
-				//fmt.Println("lengthString = ", i)
-	
-				// add 1 to jump to the 0
-				return i + 1, nil
-			}
-	
+	for _, c := range e.children {
+		if c.name != "immediate" && c.name != "delayed" {
+			continue
 		}
-	
-		err := fmt.Errorf("no string bytes found, returning 0")
-		return 0, err
-	}
-	`
-	fmt.Fprintln(p.output, txt)
-}
-
-// concatenateSlice will take all the string elements of
-// a slice, and return them as a single string.
-func concatenateSlice(s []string) string {
-	var output string
-	for _, v := range s {
-		output += v
+		v, err := parseExpectations(c.text, c.name == "delayed")
+		if err != nil {
+			return nil, fmt.Errorf("%v expectations: %v", c.name, err)
+		}
+		exps = append(exps, v...)
 	}
 
-	return output
+	return exps, nil
 }
 
-// ---------------------------------------HERE----------------------------------------
-type argument struct {
-	name    string
-	xmlType string
-	goType  string
-	length  string
-}
-
-// newArgBufferForCmd Will create a buffer starting at a cmd startTag, and ending
-// at a cmd stopTag, so it will be simpler to parse out the arguments for a specific
-// cmd.
-func (p *parser) newArgBufferForCmd(buf *Buffer) (argBuffer []argument, err error) {
-	//fmt.Println("---buf---", buf)
-
-	foundCMDStartTag := false
-	//find the position of start of cmd
-	for i, v := range buf.Slice {
-		if v.TokenType == tokenStartTag && v.TokenText == "cmd" {
-			foundCMDStartTag = true
+// parseExpectations will parse the text of an <immediate> or <delayed>
+// tag, which is a list of expected messages like
+// #1-4-3(state: inProgress, reason: userRequest). A message starting
+// with a | is an alternative to the message before it.
+func parseExpectations(text string, delayed bool) ([]*model.Expectation, error) {
+	var exps []*model.Expectation
+
+	s := strings.TrimSpace(text)
+	for s != "" {
+		alternative := s[0] == '|'
+		if alternative {
+			if len(exps) == 0 {
+				return nil, fmt.Errorf("alternative %q given before any message", s)
+			}
+			s = strings.TrimSpace(s[1:])
 		}
 
-		if foundCMDStartTag && v.TokenType == tokenEndTag && v.TokenText == "cmd" {
-			// When reached the end of the cmd, we are done with all arguments,
-			// and can return the argument slice []argument
-			return argBuffer, nil
+		msg, rest, err := parseExpectedMsg(s)
+		if err != nil {
+			return nil, err
 		}
 
-		if buf.Slice[i].TokenText == "arg" && buf.Slice[i+1].TokenText == "name" {
-			a := argument{}
-			a.name = buf.Slice[i+2].TokenText
-			// check if the name is == type, and add an X to not conflict with go's
-			// type system.
-			if a.name == "type" {
-				a.name += "X"
-			}
-
-			// check if the name contains underscores, and if it does, remove them.
-			underScore := strings.Contains(a.name, "_")
-			if underScore {
-				s := strings.Split(a.name, "_")
-				a.name = concatenateSlice(s)
-			}
+		if alternative {
+			last := exps[len(exps)-1]
+			last.OneOf = append(last.OneOf, msg)
+		} else {
+			exps = append(exps, &model.Expectation{Delayed: delayed, OneOf: []*model.ExpectedMsg{msg}})
+		}
 
-			typ := buf.Slice[i+4].TokenText
+		s = strings.TrimSpace(rest)
+	}
 
-			// lookup, and pick the needed values from the type specification map.
-			v, ok := p.droneTypesToGoTypes[typ]
-			if ok {
-				a.xmlType = typ
-				a.goType = v.name
-				a.length = v.length
-			}
+	return exps, nil
+}
 
-			// TODO: Add the different <enum specifications> as comments, so the user
-			// will know what values to enter, or what values where received.
+// parseExpectedMsg will parse a single expected message in the start of
+// s, and return it together with the rest of s.
+func parseExpectedMsg(s string) (*model.ExpectedMsg, string, error) {
+	if !strings.HasPrefix(s, "#") {
+		return nil, "", fmt.Errorf("expected a message reference starting with # at %q", s)
+	}
 
-			argBuffer = append(argBuffer, a)
+	end := strings.IndexFunc(s[1:], func(r rune) bool {
+		return (r < '0' || r > '9') && r != '-'
+	})
+	if end == -1 {
+		end = len(s)
+	} else {
+		end++
+	}
 
-			//fmt.Println("--------------------a.name---------------------------", a)
+	msg := &model.ExpectedMsg{Ref: s[:end]}
+	var ids []int
+	for _, v := range strings.Split(s[1:end], "-") {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, "", fmt.Errorf("bad message reference %q: %v", msg.Ref, err)
 		}
+		ids = append(ids, id)
 	}
 
-	if !foundCMDStartTag {
-		return nil, fmt.Errorf("no start tags to parse arguments inside found")
+	// A feature have no class, and its messages are referred to with
+	// only the feature and message ids.
+	switch len(ids) {
+	case 2:
+		msg.Project, msg.Cmd = ids[0], ids[1]
+	case 3:
+		msg.Project, msg.Class, msg.Cmd = ids[0], ids[1], ids[2]
+	default:
+		return nil, "", fmt.Errorf("bad message reference %q", msg.Ref)
 	}
 
-	return argBuffer, nil
-}
-
-// newPartialBuffer takes a *lexml.Buffer as input, and returns the first two
-// portions of that buffer forming a start -> stop token sequence.
-func newPartialBuffer(buf *Buffer) (firstBuffer []lexml.Token, secondBuffer []lexml.Token) {
-	endTagPosition1 := 0
-	endTagPosition2 := 0
-	buf1 := buf.Slice
+	rest := s[end:]
+	if !strings.HasPrefix(rest, "(") {
+		return msg, rest, nil
+	}
 
-	for i, v := range buf1 {
-		// If it is the first position in slice, just continue with the next iteration.
-		if i == 0 {
-			continue
+	closing := strings.IndexByte(rest, ')')
+	if closing == -1 {
+		return nil, "", fmt.Errorf("missing ) for the arguments of %v", msg.Ref)
+	}
+	for _, v := range strings.Split(rest[1:closing], ",") {
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) != 2 {
+			return nil, "", fmt.Errorf("bad argument %q for %v", strings.TrimSpace(v), msg.Ref)
 		}
 
-		// Check for a start or end tag. We also need to check if there are a
-		// start tag after the first start tag at position 0 in the buffer,
-		if v.TokenType == tokenEndTag || v.TokenType == tokenStartTag {
-			endTagPosition1 = i
-			break
+		arg := &model.ArgPredicate{Name: strings.TrimSpace(kv[0])}
+		value := strings.TrimSpace(kv[1])
+		if strings.HasPrefix(value, "this.") {
+			arg.This = strings.TrimPrefix(value, "this.")
+		} else {
+			arg.Value = value
 		}
+		msg.Args = append(msg.Args, arg)
 	}
 
-	buf2 := buf1[endTagPosition1:]
+	return msg, rest[closing+1:], nil
+}
 
-	// Get next series of tokens between a start and stop tag
-	for i, v := range buf2 {
-		// If it is the first position in slice, just continue with the next iteration.
-		if i == 0 {
-			continue
-		}
+// doTagComment will do all the parsing of a comment tag.
+func doTagComment(e *element) model.Comment {
+	return model.Comment{
+		Title:     e.attributes["title"],
+		Desc:      e.attributes["desc"],
+		Support:   e.attributes["support"],
+		Result:    e.attributes["result"],
+		Triggered: e.attributes["triggered"],
+	}
+}
+
+// doTagArg will do all the parsing of an argument tag.
+func doTagArg(e *element) *model.Arg {
+	arg := &model.Arg{
+		Name:    e.attributes["name"],
+		Type:    e.attributes["type"],
+		Comment: e.text,
+	}
 
-		// Check for a start or end tag. We also need to check if there are a
-		// start tag after the first start tag at position 0 in the buffer,
-		if v.TokenType == tokenEndTag || v.TokenType == tokenStartTag {
-			endTagPosition2 = i
-			break
+	for _, c := range e.children {
+		switch c.name {
+		case "comment":
+			// Some arguments have their description in a comment tag
+			// instead of as text.
+			if arg.Comment == "" {
+				arg.Comment = c.attributes["desc"]
+			}
+		case "enum":
+			// The values of an argument enum are given as enum tags
+			// directly inside the argument, and are numbered in the
+			// order they are declared.
+			if arg.Enum == nil {
+				arg.Enum = &model.Enum{Name: arg.Name, Comment: arg.Comment}
+			}
+			arg.Enum.Values = append(arg.Enum.Values, &model.Value{
+				Name:    c.attributes["name"],
+				Comment: c.text,
+				Value:   len(arg.Enum.Values),
+			})
 		}
 	}
 
-	return buf1[:endTagPosition1], buf2[:endTagPosition2]
+	return arg
 }
 
-//createLiterals will create a literal string. Takes []string and return a string to the caller.
-func createLiteral(s []string) string {
-	var tmpString string
+// element is a generic xml element built from the lexml tokens, and
+// is the step between the tokens and the protocol model.
+type element struct {
+	name       string
+	attributes map[string]string
+	text       string
+	children   []*element
+}
 
-	for i := 0; i < len(s); i++ {
-		if i < len(s)-2 {
-			v := strings.TrimSpace(s[i])
-			tmpString = fmt.Sprintf("%v%v.", tmpString, v)
-		}
-		if i == len(s)-2 {
-			v := strings.TrimSpace(s[i])
-			tmpString = fmt.Sprintf("%v%v", tmpString, v)
-		}
-		if i == len(s)-1 {
-			v := strings.TrimSpace(s[i])
-			tmpString = fmt.Sprintf(`%v="%v"`, tmpString, v)
-		}
+// attributeInt will return the value of the named attribute as an int.
+func (e *element) attributeInt(name string) (int, error) {
+	v, ok := e.attributes[name]
+	if !ok {
+		return 0, fmt.Errorf("missing %v attribute", name)
 	}
-	return tmpString
 
+	i, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("%v attribute: %v", name, err)
+	}
+
+	return i, nil
 }
 
 // tagStack will keep track of where we are working in the iteration,
 type tagStack struct {
-	data []string
+	data []*element
 }
 
 // newTagStack is a push/pop storage for tags.
 func newTagStack() *tagStack {
-	return &tagStack{
-		//data: make([]string, 0, 100),
-	}
+	return &tagStack{}
 }
 
 // push will add another item to the end of the stack with a normal append
-func (s *tagStack) push(d string) {
-	s.data = append(s.data, d)
-	//*fmt.Println("DEBUG: Put on stack : ", s)
+func (s *tagStack) push(e *element) {
+	s.data = append(s.data, e)
 }
 
 // pop will remove the last element of the stack
 func (s *tagStack) pop() {
-	//*fmt.Println("DEBUG: Before pop:", s)
-	last := len(s.data)
-	// ---
 	if len(s.data) == 0 {
 		return
 	}
-	s.data = append(s.data[0:0], s.data[:last-1]...)
-	//*fmt.Println("DEBUG: After pop:", s)
-
+	s.data = s.data[:len(s.data)-1]
 }
 
-func getLengthOfStringData(b []byte) (int, error) {
-	// Figure out the length of the string
-	for i := 0; i < cap(b); i++ {
-		//fmt.Printf("%+v, of type %T\n", b[i], b[i])
-
-		//fmt.Println("i = ", i)
-		if b[i] == 0 {
-			//fmt.Println("lengthString = ", i)
-
-			// add 1 to jump to the 0
-			return i + 1, nil
-		}
-
-	}
-
-	err := fmt.Errorf("no string bytes found, returning 0")
-	return 0, err
+// top will return the last element of the stack without removing it.
+func (s *tagStack) top() *element {
+	return s.data[len(s.data)-1]
 }
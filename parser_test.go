@@ -0,0 +1,392 @@
+package lexmlparser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/postmannen/lexml"
+	"github.com/postmannen/lexmlparser/model"
+)
+
+// parseString will lex and parse the xml given as a string.
+func parseString(t *testing.T, xml string) *model.Protocol {
+	t.Helper()
+
+	proto, err := Parse(lexml.LexStart(strings.NewReader(xml)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	return proto
+}
+
+const testProjectXML = `<?xml version="1.0" encoding="UTF-8"?>
+<project name="ardrone3" id="1">
+	All ARDrone3-only commands
+	<class name="Piloting" id="0">
+		All commands related to piloting the drone
+		<cmd name="PCMD" id="2" buffer="NON_ACK">
+			<comment
+				title="Move the drone"
+				desc="Move the drone."/>
+			<arg name="flag" type="u8">
+				Boolean flag: 1 if the roll and pitch values should be taken in consideration. 0 otherwise
+			</arg>
+			<arg name="roll" type="i8">
+				Roll angle as signed percentage.
+			</arg>
+		</cmd>
+		<cmd name="Emergency" id="4" buffer="HIGH_PRIO" timeout="RETRY">
+			<comment
+				title="Cut out the motors"
+				desc="Cut out the motors."/>
+			<expectations>
+				<immediate>
+					#1-4-1(state: landed, reason: this.cause)
+					|#1-4-1(state: takingoff)
+				</immediate>
+				<delayed>
+					#135-2
+				</delayed>
+			</expectations>
+		</cmd>
+	</class>
+	<class name="PilotingState" id="4">
+		State from drone
+		<cmd name="FlyingStateChanged" id="1" deprecated="true">
+			<arg name="state" type="enum">
+				Drone flying state
+				<enum name="landed">
+					Landed state
+				</enum>
+				<enum name="takingoff">
+					Taking off state
+				</enum>
+			</arg>
+		</cmd>
+	</class>
+</project>
+`
+
+func TestParseProject(t *testing.T) {
+	proto := parseString(t, testProjectXML)
+
+	if len(proto.Projects) != 1 {
+		t.Fatalf("got %v projects, want 1", len(proto.Projects))
+	}
+	project := proto.Projects[0]
+	if project.Name != "ardrone3" || project.ID != 1 || project.Comment != "All ARDrone3-only commands" {
+		t.Errorf("got project %+v", project)
+	}
+	if len(project.Classes) != 2 {
+		t.Fatalf("got %v classes, want 2", len(project.Classes))
+	}
+
+	piloting := project.Classes[0]
+	if piloting.Project != project || len(piloting.Cmds) != 2 {
+		t.Fatalf("got class %+v", piloting)
+	}
+
+	pcmd := piloting.Cmds[0]
+	if pcmd.Name != "PCMD" || pcmd.ID != 2 || pcmd.Class != piloting {
+		t.Errorf("got cmd %+v", pcmd)
+	}
+	if pcmd.Buffer != model.BufferNonAck || pcmd.Timeout != model.TimeoutPop {
+		t.Errorf("got buffer %v and timeout %v", pcmd.Buffer, pcmd.Timeout)
+	}
+	if pcmd.Comment.Title != "Move the drone" || pcmd.Comment.Desc != "Move the drone." {
+		t.Errorf("got comment %+v", pcmd.Comment)
+	}
+	if len(pcmd.Args) != 2 || pcmd.Args[1].Name != "roll" || pcmd.Args[1].Type != "i8" {
+		t.Errorf("got args %+v", pcmd.Args)
+	}
+
+	emergency := piloting.Cmds[1]
+	if emergency.Buffer != model.BufferHighPrio || emergency.Timeout != model.TimeoutRetry {
+		t.Errorf("got buffer %v and timeout %v", emergency.Buffer, emergency.Timeout)
+	}
+
+	if len(emergency.Expectations) != 2 {
+		t.Fatalf("got %v expectations, want 2", len(emergency.Expectations))
+	}
+	immediate := emergency.Expectations[0]
+	if immediate.Delayed || len(immediate.OneOf) != 2 {
+		t.Fatalf("got expectation %+v", immediate)
+	}
+	if m := immediate.OneOf[0]; m.Ref != "#1-4-1" || m.Project != 1 || m.Class != 4 || m.Cmd != 1 || len(m.Args) != 2 {
+		t.Errorf("got expected msg %+v", m)
+	}
+	if a := immediate.OneOf[0].Args[1]; a.Name != "reason" || a.This != "cause" || a.Value != "" {
+		t.Errorf("got arg predicate %+v", a)
+	}
+	if a := immediate.OneOf[1].Args[0]; a.Name != "state" || a.Value != "takingoff" {
+		t.Errorf("got arg predicate %+v", a)
+	}
+	delayed := emergency.Expectations[1]
+	if m := delayed.OneOf[0]; !delayed.Delayed || m.Project != 135 || m.Class != 0 || m.Cmd != 2 || len(m.Args) != 0 {
+		t.Errorf("got expectation %+v with msg %+v", delayed, m)
+	}
+
+	flying := project.Classes[1].Cmds[0]
+	if !flying.Deprecated || flying.Buffer != model.BufferAck {
+		t.Errorf("got cmd %+v", flying)
+	}
+	state := flying.Args[0]
+	if state.Enum == nil || len(state.Enum.Values) != 2 {
+		t.Fatalf("got enum %+v", state.Enum)
+	}
+	if v := state.Enum.Values[1]; v.Name != "takingoff" || v.Value != 1 || v.Comment != "Taking off state" {
+		t.Errorf("got enum value %+v", v)
+	}
+}
+
+const testFeatureXML = `<?xml version="1.0" encoding="UTF-8"?>
+<feature id="135" name="wifi">
+	All commands/events related to the Wifi
+	<enums>
+		<enum name="band">
+			Wifi band
+			<value name="2_4ghz">
+				2.4 GHz band
+			</value>
+			<value name="5ghz">
+				5 GHz band
+			</value>
+		</enum>
+	</enums>
+	<msgs>
+		<cmd name="scan" id="1">
+			<comment
+				title="Scan wifi network"
+				desc="Launches wifi network scan."/>
+			<arg name="band" type="bitfield:u8:band"/>
+		</cmd>
+		<evt name="scanned_item" id="2" type="MAP_ITEM:ssid">
+			<arg name="ssid" type="string">
+				SSID of the AP
+			</arg>
+			<arg name="band" type="enum:band">
+			</arg>
+			<arg name="list_flags" type="bitfield:u8:list_flags"/>
+		</evt>
+	</msgs>
+</feature>
+`
+
+func TestParseFeature(t *testing.T) {
+	proto := parseString(t, testFeatureXML)
+
+	if len(proto.Projects) != 1 {
+		t.Fatalf("got %v projects, want 1", len(proto.Projects))
+	}
+	feature := proto.Projects[0]
+	if !feature.Feature || feature.Name != "wifi" || feature.ID != 135 {
+		t.Errorf("got feature %+v", feature)
+	}
+	if len(feature.Classes) != 0 || len(feature.Msgs) != 2 {
+		t.Fatalf("got %v classes and %v msgs, want 0 and 2", len(feature.Classes), len(feature.Msgs))
+	}
+
+	scan := feature.Msgs[0]
+	if scan.Event || scan.ID != 1 || scan.Class != nil || scan.Project != feature || scan.ClassID() != 0 {
+		t.Errorf("got cmd %+v", scan)
+	}
+	if len(scan.Args) != 1 || scan.Args[0].Type != "bitfield:u8:band" {
+		t.Errorf("got args %+v", scan.Args)
+	}
+
+	band := feature.Enum("band")
+	if band == nil || band.Comment != "Wifi band" || len(band.Values) != 2 {
+		t.Fatalf("got enum %+v", band)
+	}
+	if v := band.Values[1]; v.Name != "5ghz" || v.Value != 1 || v.Comment != "5 GHz band" {
+		t.Errorf("got enum value %+v", v)
+	}
+
+	item := feature.Msgs[1]
+	if !item.Event || item.Name != "scanned_item" || len(item.Args) != 3 {
+		t.Errorf("got evt %+v", item)
+	}
+	if item.ListType != model.MapItem || item.MapKey != "ssid" || item.ListFlags() != item.Args[2] {
+		t.Errorf("got list type %q with key %q", item.ListType, item.MapKey)
+	}
+	if scan := feature.Msgs[0]; scan.ListType != model.ListNone || scan.ListFlags() != nil {
+		t.Errorf("got list type %q for cmd scan", scan.ListType)
+	}
+}
+
+func TestParseListType(t *testing.T) {
+	// The first argument is the key of a map item if no key is given.
+	xml := strings.Replace(testFeatureXML, `type="MAP_ITEM:ssid"`, `type="MAP_ITEM"`, 1)
+	item := parseString(t, xml).Projects[0].Msgs[1]
+	if item.ListType != model.MapItem || item.MapKey != "ssid" {
+		t.Errorf("got list type %q with key %q", item.ListType, item.MapKey)
+	}
+
+	xml = strings.Replace(testFeatureXML, `type="MAP_ITEM:ssid"`, `type="LIST_ITEM"`, 1)
+	item = parseString(t, xml).Projects[0].Msgs[1]
+	if item.ListType != model.ListItem || item.MapKey != "" {
+		t.Errorf("got list type %q with key %q", item.ListType, item.MapKey)
+	}
+
+	for typ, want := range map[string]string{
+		"MAP_ITEM:bssid":  `map key "bssid" is not an argument`,
+		"LIST_ITEM:ssid":  `unknown type "LIST_ITEM:ssid"`,
+		"ARRAY_ITEM:ssid": `unknown type "ARRAY_ITEM:ssid"`,
+	} {
+		xml := strings.Replace(testFeatureXML, `type="MAP_ITEM:ssid"`, `type="`+typ+`"`, 1)
+		_, err := Parse(lexml.LexStart(strings.NewReader(xml)))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %v for type %v, want %v", err, typ, want)
+		}
+	}
+}
+
+func TestParseExpectationsError(t *testing.T) {
+	for _, text := range []string{"1-4-1", "|#1-4-1", "#1-4-1(state landed)", "#1-4-1(state: landed", "#1"} {
+		_, err := parseExpectations(text, false)
+		if err == nil {
+			t.Errorf("got no error for %q", text)
+		}
+	}
+}
+
+func TestParseUnknownBuffer(t *testing.T) {
+	xml := strings.Replace(testProjectXML, `buffer="NON_ACK"`, `buffer="SOMETIMES"`, 1)
+
+	_, err := Parse(lexml.LexStart(strings.NewReader(xml)))
+	if err == nil || !strings.Contains(err.Error(), `unknown buffer "SOMETIMES"`) {
+		t.Errorf("got error %v, want an error about the unknown buffer", err)
+	}
+}
+
+// writeFile will write the xml to a file in dir, and return its name.
+func writeFile(t *testing.T, dir string, name string, xml string) string {
+	t.Helper()
+
+	name = filepath.Join(dir, name)
+	if err := ioutil.WriteFile(name, []byte(xml), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return name
+}
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	project := writeFile(t, dir, "ardrone3.xml", testProjectXML)
+	feature := writeFile(t, dir, "wifi.xml", testFeatureXML)
+
+	proto, err := ParseFiles(project, feature)
+	if err != nil {
+		t.Fatalf("ParseFiles: %v", err)
+	}
+	if len(proto.Projects) != 2 || proto.Projects[0].Name != "ardrone3" || proto.Projects[1].Name != "wifi" {
+		t.Errorf("got projects %v", proto.Projects)
+	}
+}
+
+func TestParseFilesCollision(t *testing.T) {
+	dir := t.TempDir()
+	project := writeFile(t, dir, "ardrone3.xml", testProjectXML)
+	tests := []struct {
+		xml  string
+		want string
+	}{
+		{
+			xml:  strings.Replace(testProjectXML, `name="ardrone3"`, `name="jpsumo"`, 1),
+			want: "project jpsumo (id 1): id is already used by project ardrone3 (id 1)",
+		},
+		{
+			xml:  strings.Replace(testProjectXML, `id="1"`, `id="3"`, 1),
+			want: "project ardrone3 (id 3): name is already used by the project with id 1",
+		},
+		{
+			xml:  strings.Replace(testFeatureXML, `id="2"`, `id="1"`, 1),
+			want: "feature wifi (id 135): scanned_item: id 1 is already used by scan",
+		},
+	}
+
+	for _, tt := range tests {
+		other := writeFile(t, dir, "other.xml", tt.xml)
+
+		_, err := ParseFiles(project, other)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("got error %v, want %v", err, tt.want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	dir := t.TempDir()
+	project := writeFile(t, dir, "ardrone3.xml", testProjectXML)
+	feature := writeFile(t, dir, "wifi.xml", testFeatureXML)
+
+	sum, err := Checksum(project, feature)
+	if err != nil {
+		t.Fatalf("Checksum: %v", err)
+	}
+	if !strings.HasPrefix(sum, "sha256:") || len(sum) != len("sha256:")+64 {
+		t.Errorf("got checksum %v", sum)
+	}
+
+	writeFile(t, dir, "wifi.xml", strings.Replace(testFeatureXML, "5 GHz", "5GHz", 1))
+	changed, err := Checksum(project, feature)
+	if err != nil {
+		t.Fatalf("Checksum: %v", err)
+	}
+	if changed == sum {
+		t.Errorf("got the same checksum %v after the xml changed", sum)
+	}
+}
+
+const testMultisettingXML = `<?xml version="1.0" encoding="UTF-8"?>
+<feature id="133" name="generic">
+	All generic messages
+	<multisettings>
+		<multisetting name="DroneSettings">
+			Drone settings
+			<member link="ardrone3.Piloting.PCMD"></member>
+			<member link="ardrone3.PilotingState.FlyingStateChanged"></member>
+		</multisetting>
+	</multisettings>
+	<msgs>
+		<cmd name="SetDroneSettings" id="2">
+			<arg name="settings" type="multisetting:DroneSettings">
+				<comment
+					title="SetDroneSettings"
+					desc="Set several drone settings in only one command."/>
+			</arg>
+		</cmd>
+	</msgs>
+</feature>
+`
+
+func TestParseMultisetting(t *testing.T) {
+	proto := parseString(t, testMultisettingXML)
+	proto.Projects = append(proto.Projects, parseString(t, testProjectXML).Projects...)
+
+	feature := proto.Projects[0]
+	ms := feature.Multisetting("DroneSettings")
+	if ms == nil || ms.Comment != "Drone settings" || ms.Project != feature || len(ms.Members) != 2 {
+		t.Fatalf("got multisetting %+v", ms)
+	}
+
+	pcmd := proto.Link(ms.Members[0].Link)
+	if pcmd == nil || pcmd.Name != "PCMD" || pcmd.Class.Name != "Piloting" {
+		t.Errorf("got command %+v for link %v", pcmd, ms.Members[0].Link)
+	}
+	if pcmd != nil && pcmd.Link() != ms.Members[0].Link {
+		t.Errorf("got link %v, want %v", pcmd.Link(), ms.Members[0].Link)
+	}
+	if cmd := proto.Link("generic.SetDroneSettings"); cmd == nil || cmd.ID != 2 || cmd.Link() != "generic.SetDroneSettings" {
+		t.Errorf("got command %+v for the message of a feature", cmd)
+	}
+	for _, link := range []string{"ardrone3.Piloting.Unknown", "ardrone3.Piloting", "ardrone3", "unknown.SetDroneSettings"} {
+		if cmd := proto.Link(link); cmd != nil {
+			t.Errorf("got command %+v for link %v, want nil", cmd, link)
+		}
+	}
+}
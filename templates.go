@@ -0,0 +1,651 @@
+package lexmlparser
+
+import (
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/postmannen/lexmlparser/model"
+)
+
+// CmdData is the data given to the struct, decoder, encoder and methods
+// templates for a command.
+type CmdData struct {
+	Cmd *model.Cmd
+	// TypeName is the name of the type created for the command.
+	TypeName string
+	// VarName is the name of the variable holding the Command value of
+	// the command.
+	VarName string
+	// ProjectConst, ClassConst and CmdConst are the names of the
+	// constants holding the ids of the command. ClassConst is 0 for the
+	// messages of a feature.
+	ProjectConst string
+	ClassConst   string
+	CmdConst     string
+	Args         []ArgData
+	// ListFlags is the name of the struct field for the list_flags
+	// argument of a list item, and empty if the command has none.
+	ListFlags string
+}
+
+// ArgData is an argument of a command together with the Go type it
+// will have in the generated code.
+type ArgData struct {
+	// Name is the name of the struct field for the argument.
+	Name string
+	// XMLName and XMLType are the name and type of the argument in the
+	// xml.
+	XMLName string
+	XMLType string
+	GoType  string
+	// BaseType is the Go type the value is converted to and from when
+	// it is decoded and encoded. It is the same as GoType except for
+	// the named types created for enums.
+	BaseType string
+	// Length is the number of bytes of the value, which is 0 for the
+	// strings and * for the arguments taking the rest of the payload.
+	Length string
+	// Tag is the struct tag of the field as written in the code, which
+	// holds the name of the argument in the xml.
+	Tag string
+	// Enum is the enum of the argument, if any.
+	Enum *model.Enum
+	// Multisetting is the multisetting of the argument, if any. The
+	// argument is kept as []byte if the commands of the multisetting
+	// were not found in the input.
+	Multisetting *model.Multisetting
+}
+
+// EnumData is the data given to the enum template.
+type EnumData struct {
+	Enum *model.Enum
+	// Cmd is the command the enum is created for, which is nil for the
+	// enums declared for a whole feature. The enum is deprecated with
+	// the command.
+	Cmd *model.Cmd
+	// TypeName is the name of the type created for the enum, and
+	// BaseType the Go type it is sent as.
+	TypeName string
+	BaseType string
+	// Comment is the comment of the enum, with the references to other
+	// commands replaced by doc links.
+	Comment string
+	Values  []ValueData
+}
+
+// ValueData is a value of an enum given to the enum template.
+type ValueData struct {
+	Value *model.Value
+	// Name is the name of the constant created for the value.
+	Name    string
+	Comment string
+}
+
+// BitfieldData is the data given to the bitfield template.
+type BitfieldData struct {
+	TypeName string
+	BaseType string
+	// EnumType is the type of the enum the bits refer to, qualified with
+	// the package name if the enum is created in another package.
+	EnumType string
+	// Bits is the number of bits of the bitfield.
+	Bits int
+}
+
+// MultisettingData is the data given to the multisetting template.
+type MultisettingData struct {
+	Multisetting *model.Multisetting
+	// TypeName is the name of the struct type created for the
+	// multisetting.
+	TypeName string
+	// Comment is the comment of the multisetting, with the references
+	// to other commands replaced by doc links.
+	Comment string
+	Members []MemberData
+}
+
+// MemberData is a member of a multisetting given to the templates.
+type MemberData struct {
+	// Cmd is the command the member links to.
+	Cmd *model.Cmd
+	// Field is the name of the struct field holding the arguments of the
+	// command, and IsSet is the name of the field telling if the member
+	// is set.
+	Field string
+	IsSet string
+	// ArgsType is the type of the arguments of the command, and Var the
+	// variable holding its Command value. They are qualified with the
+	// package name if the command is created in another package.
+	ArgsType string
+	Var      string
+}
+
+// RegistryData is the data given to the registry template.
+type RegistryData struct {
+	// Name is the name of the registry package.
+	Name string
+	// Imports are the import paths of the packages created for the
+	// projects, sorted.
+	Imports []string
+}
+
+// templatesText are the default templates used to create the code.
+//
+//	struct        the Arguments struct of a command, given a CmdData
+//	decoder       the Decode method of a command, given a CmdData
+//	encoder       the Encode method of a command, given a CmdData
+//	buffer        the Buffer and Timeout methods of a command, given a
+//	              CmdData
+//	expectations  the Expectations method of a command, given a CmdData,
+//	              which is empty for the commands without expectations
+//	listitem      the ItemKind, ItemKey and ItemFlags methods of the
+//	              arguments of a command which is an item of a list or a
+//	              map, given a CmdData, and empty for the other commands
+//	methods       extra methods for a command, given a CmdData, and empty
+//	              by default
+//	var           the variable holding the Command value of a command,
+//	              given a CmdData
+//	enum          the named type of an enum with its constants and String
+//	              method, given an EnumData
+//	bitfield      the named type of a bitfield with its methods, given a
+//	              BitfieldData
+//	multisetting  the struct type of a multisetting with its Encode and
+//	              Decode methods, given a MultisettingData
+//	commandmap    the CommandMap of the package, given the names of the
+//	              variables of the commands
+//	state         the State type of the package with a method for every
+//	              state event, given a CmdData for each of them
+//	dispatcher    the Dispatcher type of the package with an On method for
+//	              every command, given a CmdData for each of them
+//	commands      the Commands of the package with the metadata of every
+//	              command, and the Registry finding them, given a CmdData
+//	              for each of them
+//	registry      the registry package created by GeneratePackages, given
+//	              a RegistryData
+const templatesText = `
+{{- define "struct" -}}
+{{deprecated .Cmd}}type {{.TypeName}}Arguments struct {
+{{- range .Args}}
+{{.Name}} {{.GoType}} {{.Tag}}
+{{- end}}
+}
+
+{{end}}
+
+{{- define "decoder" -}}
+{{- $hasString := false}}
+{{- range .Args}}{{if eq .BaseType "string"}}{{$hasString = true}}{{end}}{{end -}}
+// Decode will decode the arguments of {{.TypeName}} from b.
+func (a {{.TypeName}}) Decode(b []byte) (Arguments, error) {
+{{- if $hasString}}
+var stringEnd int
+var err error
+{{- end}}
+arg := {{.TypeName}}Arguments{}
+{{- if .Args}}
+var offset = 0
+{{- range .Args}}
+{{- if .Multisetting}}
+{
+n, err := arg.{{.Name}}.Decode(b[offset:])
+if err != nil {
+return nil, &arsdk.DecodeError{Cmd: {{printf "%q" $.TypeName}}, Arg: {{printf "%q" .Name}}, Offset: offset, Err: err}
+}
+offset += n
+}
+{{- else if eq .BaseType "string"}}
+stringEnd, err = arsdk.LenStringData(b[offset:])
+if err != nil {
+return nil, &arsdk.DecodeError{Cmd: {{printf "%q" $.TypeName}}, Arg: {{printf "%q" .Name}}, Offset: offset, Err: err}
+}
+arg.{{.Name}} = string(b[offset:offset+stringEnd-1])
+offset += stringEnd
+{{- else if eq .BaseType "[]byte"}}
+arg.{{.Name}} = b[offset:]
+offset = len(b)
+{{- else}}
+if len(b) < offset+{{.Length}} {
+return nil, &arsdk.DecodeError{Cmd: {{printf "%q" $.TypeName}}, Arg: {{printf "%q" .Name}}, Offset: offset, Err: arsdk.ErrShortPayload}
+}
+if err := arsdk.ConvLittleEndianSliceToNumeric(b[offset:offset+{{.Length}}], {{if ne .GoType .BaseType}}(*{{.BaseType}})(&arg.{{.Name}}){{else}}&arg.{{.Name}}{{end}}); err != nil {
+return nil, &arsdk.DecodeError{Cmd: {{printf "%q" $.TypeName}}, Arg: {{printf "%q" .Name}}, Offset: offset, Err: err}
+}
+{{- if eq .Length "1"}}
+offset++
+{{- else}}
+offset += {{.Length}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- else}}
+// No arguments to decode here !!
+{{- end}}
+
+return arg, nil
+}
+{{end}}
+
+{{- define "encoder" -}}
+// Encode will append the arguments encoded as little endian to b, and
+// return the extended slice.
+func (a {{.TypeName}}Arguments) Encode(b []byte) []byte {
+{{- range .Args}}
+{{- if .Multisetting}}
+b = a.{{.Name}}.Encode(b)
+{{- else if eq .BaseType "string"}}
+b = append(b, a.{{.Name}}...)
+b = append(b, 0)
+{{- else if eq .BaseType "[]byte"}}
+b = append(b, a.{{.Name}}...)
+{{- else if eq .BaseType "float32"}}
+{{use "math"}}{{appendLittleEndian (printf "math.Float32bits(a.%v)" .Name) .Length}}
+{{- else if eq .BaseType "float64"}}
+{{use "math"}}{{appendLittleEndian (printf "math.Float64bits(a.%v)" .Name) .Length}}
+{{- else}}
+{{appendLittleEndian (printf "a.%v" .Name) .Length}}
+{{- end}}
+{{- end}}
+return b
+}
+
+{{end}}
+
+{{- define "buffer"}}
+// Buffer will return the buffer class the command should be sent on.
+func (a {{.TypeName}}) Buffer() arsdk.Buffer {
+return {{bufferConst .Cmd.Buffer}}
+}
+
+// Timeout will return the policy to use if the command was not acknowledged in time.
+func (a {{.TypeName}}) Timeout() arsdk.Timeout {
+return {{timeoutConst .Cmd.Timeout}}
+}
+{{end}}
+
+{{- define "expectations"}}
+{{- if .Cmd.Expectations}}
+// Expectations will return the messages expected to be received after the command was sent.
+func (a {{.TypeName}}) Expectations() []arsdk.Expectation {
+return []arsdk.Expectation{
+{{- range .Cmd.Expectations}}
+{Delayed: {{.Delayed}}, OneOf: []arsdk.ExpectedMsg{
+{{- range .OneOf}}
+{Ref: {{printf "%q" .Ref}}, Command: arsdk.Command{Project: {{.Project}}, Class: {{.Class}}, Cmd: {{.Cmd}}}
+{{- if .Args}}, Args: []arsdk.ArgPredicate{
+{{- range $i, $a := .Args}}{{if $i}}, {{end}}
+{{- if .This}}{Name: {{printf "%q" .Name}}, This: {{printf "%q" .This}}}
+{{- else}}{Name: {{printf "%q" .Name}}, Value: {{printf "%q" .Value}}}
+{{- end}}
+{{- end}}}
+{{- end}}},
+{{- end}}
+}},
+{{- end}}
+}
+}
+{{end}}
+{{- end}}
+
+{{- define "listitem"}}
+{{- if .Cmd.ListType}}
+// ItemKind will return the kind of list the arguments are an item of.
+func (a {{.TypeName}}Arguments) ItemKind() arsdk.ListKind {
+return {{if eq .Cmd.ListType "MAP_ITEM"}}arsdk.MapItem{{else}}arsdk.ListItem{{end}}
+}
+{{if eq .Cmd.ListType "MAP_ITEM"}}
+// ItemKey will return the key of the item in the map.
+func (a {{.TypeName}}Arguments) ItemKey() interface{} {
+{{- range .Args}}{{if eq .XMLName $.Cmd.MapKey}}
+return a.{{.Name}}
+{{- end}}{{end}}
+}
+{{- else}}
+{{- /* The items of a list have no key, so an item is identified by all
+its arguments except the list flags, which are different when an item
+is removed. */}}
+// ItemKey will return the item without its list flags.
+func (a {{.TypeName}}Arguments) ItemKey() interface{} {
+{{- with .ListFlags}}
+a.{{.}} = 0
+{{- end}}
+return a
+}
+{{- end}}
+{{with .ListFlags}}
+// ItemFlags will return where the item is in the list.
+func (a {{$.TypeName}}Arguments) ItemFlags() arsdk.ListFlags {
+return arsdk.ListFlags(a.{{.}})
+}
+{{end}}
+{{- end}}
+{{- end}}
+
+{{- define "methods"}}{{end}}
+
+{{- define "var"}}
+{{deprecated .Cmd}}var {{.VarName}} = {{.TypeName}}{
+Project: {{.ProjectConst}},
+Class: {{.ClassConst}},
+Cmd: {{.CmdConst}},
+}
+
+{{end}}
+
+{{- define "enum" -}}
+{{- if .Comment}}
+// {{.TypeName}} : {{.Comment}}
+{{- if deprecated .Cmd}}
+//
+{{- end}}
+{{- end}}
+{{deprecated .Cmd}}type {{.TypeName}} {{.BaseType}}
+
+{{deprecated .Cmd}}const (
+{{- range .Values}}
+{{- if .Comment}}
+// {{.Comment}}
+{{- end}}
+{{.Name}} {{$.TypeName}} = {{.Value.Value}}
+{{- end}}
+)
+
+{{deprecated .Cmd}}func (e {{.TypeName}}) String() string {
+switch e {
+{{- range .Values}}
+case {{.Name}}:
+return {{printf "%q" .Value.Name}}
+{{- end}}
+}
+{{use "fmt"}}return fmt.Sprintf("{{.TypeName}}(%d)", {{.BaseType}}(e))
+}
+
+{{end}}
+
+{{- define "bitfield" -}}
+// {{.TypeName}} is a bitfield of {{.EnumType}} values.
+type {{.TypeName}} {{.BaseType}}
+
+// Has will return true if the value is set in the bitfield.
+func (b {{.TypeName}}) Has(v {{.EnumType}}) bool {
+return b&(1<<uint(v)) != 0
+}
+
+// Set will set the value in the bitfield.
+func (b *{{.TypeName}}) Set(v {{.EnumType}}) {
+*b |= 1 << uint(v)
+}
+
+// Clear will clear the value in the bitfield.
+func (b *{{.TypeName}}) Clear(v {{.EnumType}}) {
+*b &^= 1 << uint(v)
+}
+
+// Values will return all the values set in the bitfield.
+func (b {{.TypeName}}) Values() []{{.EnumType}} {
+var values []{{.EnumType}}
+for i := uint(0); i < {{.Bits}}; i++ {
+if b&(1<<i) != 0 {
+values = append(values, {{.EnumType}}(i))
+}
+}
+return values
+}
+
+// String will return the names of the values set in the bitfield separated by |.
+func (b {{.TypeName}}) String() string {
+var s string
+for i, v := range b.Values() {
+if i > 0 {
+s += "|"
+}
+s += v.String()
+}
+return s
+}
+
+{{end}}
+
+{{- define "multisetting" -}}
+{{- if .Comment}}
+// {{.TypeName}} : {{.Comment}}
+//
+{{- end}}
+// Only the members with the IsSet field set to true are encoded, and the
+// IsSet field is set to true for the members found when decoding.
+type {{.TypeName}} struct {
+{{- range .Members}}
+{{.Field}} {{.ArgsType}}
+{{.IsSet}} bool
+{{- end}}
+}
+
+// Encode will append the members set to b, and return the extended slice.
+func (m {{.TypeName}}) Encode(b []byte) []byte {
+var members []byte
+{{- range .Members}}
+if m.{{.IsSet}} {
+members = arsdk.AppendMultisettingMember(members, Command({{.Var}}), m.{{.Field}})
+}
+{{- end}}
+return arsdk.AppendMultisetting(b, members)
+}
+
+// Decode will decode the members in the start of b, and return the number
+// of bytes used. The members not known are skipped.
+func (m *{{.TypeName}}) Decode(b []byte) (int, error) {
+members, n, err := arsdk.DecodeMultisetting(b)
+if err != nil {
+return 0, err
+}
+for _, v := range members {
+switch v.Command {
+{{- range .Members}}
+case Command({{.Var}}):
+args, err := {{.Var}}.Decode(v.Payload)
+if err != nil {
+return 0, err
+}
+m.{{.Field}} = args.({{.ArgsType}})
+m.{{.IsSet}} = true
+{{- end}}
+}
+}
+return n, nil
+}
+
+{{end}}
+
+{{- define "commandmap" -}}
+var CommandMap = map[Command]Decoder{
+{{- range .}}
+Command({{.}}): {{.}},
+{{- end}}
+}
+
+{{end}}
+
+{{- define "state" -}}
+// StateCommands are the state events kept by a State.
+var StateCommands = map[Command]bool{
+{{- range .}}
+Command({{.VarName}}): true,
+{{- end}}
+}
+
+// State holds the last arguments received for every state event, with a
+// method returning the arguments of each of them. Give it to
+// arnetworkal.Dispatch to keep it up to date with the events received.
+type State struct {
+*arsdk.State
+}
+
+// NewState will return a new State where no states are received yet.
+func NewState() State {
+return State{arsdk.NewState(StateCommands)}
+}
+{{range .}}
+// {{.TypeName}} will return the last arguments received for the event,
+// and false if none were received.
+{{- if .Cmd.Deprecated}}
+//
+{{deprecated .Cmd}}
+{{- else}}
+{{end -}}
+func (s State) {{.TypeName}}() ({{.TypeName}}Arguments, bool) {
+a, ok := s.Get(Command({{.VarName}})).({{.TypeName}}Arguments)
+return a, ok
+}
+{{end}}
+{{end}}
+
+{{- define "dispatcher" -}}
+// Dispatcher calls the handlers registered for a command every time the
+// command is received, with the arguments struct of the command. Give it
+// to arnetworkal.Dispatch to call the handlers for the commands decoded.
+type Dispatcher struct {
+*arsdk.Dispatcher
+}
+
+// NewDispatcher will return a new Dispatcher with no handlers.
+func NewDispatcher() Dispatcher {
+return Dispatcher{arsdk.NewDispatcher()}
+}
+{{range .}}
+// On{{.TypeName}} will call fn every time {{.TypeName}} is received,
+// and return a function which removes the handler.
+{{- if .Cmd.Deprecated}}
+//
+{{deprecated .Cmd}}
+{{- else}}
+{{end -}}
+func (d Dispatcher) On{{.TypeName}}(fn func({{.TypeName}}Arguments)) (unsubscribe func()) {
+return d.On(Command({{.VarName}}), func(args Arguments) {
+if a, ok := args.({{.TypeName}}Arguments); ok {
+fn(a)
+}
+})
+}
+{{end}}
+{{end}}
+
+{{- define "commands" -}}
+// Commands are all the commands of the package, with what the xml tells
+// about them.
+var Commands = []arsdk.CommandInfo{
+{{- range .}}
+{Command: Command({{.VarName}}), Name: {{printf "%q" .Cmd.Link}}, Decoder: {{.VarName}}, Args: {{.TypeName}}Arguments{}
+{{- if .Cmd.IsEvent}}, Event: true{{end}}
+{{- if .Cmd.Deprecated}}, Deprecated: true{{end}}
+{{- if .Cmd.IsState}}, State: true{{end}}},
+{{- end}}
+}
+
+// Registry finds the commands of the package by their name, their ids or
+// the type of their arguments.
+var Registry = arsdk.NewRegistry(Commands)
+
+{{end}}
+
+{{- define "registry" -}}
+// Package {{.Name}} holds the commands of all the projects and features.
+package {{.Name}}
+
+import (
+	"github.com/postmannen/lexmlparser/arsdk"
+
+{{range .Imports}}	{{printf "%q" .}}
+{{end -}}
+)
+
+// CommandMap maps the commands of all the packages to their decoders.
+var CommandMap = map[arsdk.Command]arsdk.Decoder{}
+
+// Registry finds the commands of all the packages by their name, their
+// ids or the type of their arguments.
+var Registry = arsdk.NewRegistry(
+{{range .Imports}}{{base .}}.Commands,
+{{end -}}
+)
+
+// StateCommands are the state events of all the packages. An
+// arsdk.State created with them can be used with the State type of
+// every package, like ardrone3.State{State: s}.
+var StateCommands = map[arsdk.Command]bool{}
+
+func init() {
+for _, m := range []map[arsdk.Command]arsdk.Decoder{
+{{range .Imports}}{{base .}}.CommandMap,
+{{end -}}
+} {
+for k, v := range m {
+CommandMap[k] = v
+}
+}
+for _, m := range []map[arsdk.Command]bool{
+{{range .Imports}}{{base .}}.StateCommands,
+{{end -}}
+} {
+for k, v := range m {
+StateCommands[k] = v
+}
+}
+}
+{{end}}
+`
+
+// defaultTemplates are the templates used to create the code when no
+// other templates are given.
+var defaultTemplates = template.Must(template.New("").Funcs(templateFuncs(nil)).Parse(templatesText))
+
+// templateFuncs will return the functions available to the templates.
+// The packages used by the code are recorded in imports, so only the
+// packages used are imported.
+func templateFuncs(imports map[string]bool) template.FuncMap {
+	return template.FuncMap{
+		// use will record that the code uses the package.
+		"use": func(pkg string) string {
+			if imports != nil {
+				imports[pkg] = true
+			}
+			return ""
+		},
+		"appendLittleEndian": appendLittleEndian,
+		"deprecated":         deprecatedNotice,
+		"bufferConst":        bufferConstName,
+		"timeoutConst":       timeoutConstName,
+		"base":               path.Base,
+	}
+}
+
+// LoadTemplates will return the default templates, where the templates
+// with the same name as a .tmpl file in dir are replaced by the content
+// of the file. A file can also define new templates with define, which
+// can be used by the templates replaced.
+// To add methods to every command, put them in methods.tmpl.
+func LoadTemplates(dir string) (*template.Template, error) {
+	t, err := defaultTemplates.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = t.New(strings.TrimSuffix(filepath.Base(name), ".tmpl")).Parse(string(b))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,118 @@
+package lexmlparser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	// The methods template is empty by default, and adds a method to
+	// every command when given.
+	writeFile(t, dir, "methods.tmpl", `func (a {{.TypeName}}) Name() string {
+return {{printf "%q" .Cmd.Name}}
+}
+`)
+	// The encoder template is replaced, and uses a template defined in
+	// the same file.
+	writeFile(t, dir, "encoder.tmpl", `{{define "encodeComment"}}// Encode is not supported.{{end -}}
+{{template "encodeComment"}}
+func (a {{.TypeName}}Arguments) Encode(b []byte) []byte {
+panic("not supported")
+}
+`)
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+
+	// The float is encoded with the math package by the default encoder.
+	xml := strings.Replace(testProjectXML, `name="roll" type="i8"`, `name="roll" type="float"`, 1)
+
+	var buf bytes.Buffer
+	err = Generate(&buf, parseString(t, xml), Options{Templates: templates})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	code := buf.String()
+	checkContains(t, code,
+		"func (a Ardrone3PilotingPCMD) Name() string {\nreturn \"PCMD\"\n}\n",
+		"// Encode is not supported.\nfunc (a Ardrone3PilotingPCMDArguments) Encode(b []byte) []byte {\npanic(\"not supported\")\n}\n",
+		// The templates not replaced are the defaults.
+		"func (a Ardrone3PilotingPCMD) Decode(b []byte) (Arguments, error) {",
+	)
+	if strings.Contains(code, `"math"`) {
+		t.Errorf("math is imported, but is only used by the encoder replaced")
+	}
+}
+
+func TestLoadTemplatesError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "decoder.tmpl", `{{.TypeName`)
+
+	_, err := LoadTemplates(dir)
+	if err == nil {
+		t.Errorf("got no error for a malformed template")
+	}
+}
+
+func TestLoadTemplatesEnumAndVar(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "enum.tmpl", `type {{.TypeName}} {{.BaseType}}
+{{range .Values}}
+const {{.Name}} {{$.TypeName}} = {{.Value.Value}}
+{{end}}
+`)
+	writeFile(t, dir, "var.tmpl", `
+// {{.VarName}} is {{.ProjectConst}}, {{.ClassConst}}, {{.CmdConst}}.
+var {{.VarName}} = {{.TypeName}}{ {{.ProjectConst}}, {{.ClassConst}}, {{.CmdConst}} }
+`)
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = Generate(&buf, parseString(t, testProjectXML), Options{Templates: templates})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	code := buf.String()
+	checkContains(t, code,
+		"const Ardrone3PilotingStateFlyingStateChangedStateLanded Ardrone3PilotingStateFlyingStateChangedState = 0\n",
+		"// PilotingPCMD is ProjectArdrone3, Ardrone3PilotingClassPiloting, Ardrone3PilotingCmdPCMD.\n",
+		"var PilotingPCMD = Ardrone3PilotingPCMD{ProjectArdrone3, Ardrone3PilotingClassPiloting, Ardrone3PilotingCmdPCMD}\n",
+	)
+	if strings.Contains(code, `"fmt"`) {
+		t.Errorf("fmt is imported, but is only used by the enum replaced")
+	}
+}
+
+func TestLoadTemplatesListItem(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "listitem.tmpl", `{{if .Cmd.ListType}}
+// {{.TypeName}}Arguments is a {{.Cmd.ListType}} with the key {{.Cmd.MapKey}}.
+{{end}}`)
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = Generate(&buf, parseString(t, testFeatureXML), Options{Templates: templates})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	code := buf.String()
+	checkContains(t, code, "// WifiScannedItemArguments is a MAP_ITEM with the key ssid.\n")
+	if strings.Contains(code, "ItemKind()") {
+		t.Errorf("the default listitem template was used")
+	}
+}